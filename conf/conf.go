@@ -13,13 +13,30 @@ import (
 
 // Configuration is a structure which holds DDNS configuration.
 type Configuration struct {
-	Token          string
-	IPv6           bool
-	CheckPeriod    time.Duration
-	RequestTimeout time.Duration
-	Domains        map[string][]do.Record
-	Notifications  []map[string]interface{}
-	Params         map[string]string
+	Token               string
+	IPv6                bool
+	CheckPeriod         time.Duration
+	WatchNetwork        bool
+	ResyncPeriod        time.Duration
+	SyncWorkers         int
+	RequestTimeout      time.Duration
+	Domains             map[string][]do.Record
+	Providers           []map[string]interface{}
+	ProviderStrategy    string
+	Quorum              int
+	RaceStagger         time.Duration
+	FailureThreshold    int
+	ProviderCooldown    time.Duration
+	RotateProviders     bool
+	AllowPrivateIP      bool
+	Notifications       []map[string]interface{}
+	MQTT                map[string]interface{}
+	Params              map[string]string
+	StateFile           string
+	VerifyPropagation   bool
+	ResolveBeforeUpdate bool
+	PropagationTimeout  time.Duration
+	Nameservers         []string
 }
 
 // valid checks that provided configuration is valid
@@ -58,8 +75,22 @@ func NewConfiguration(path string) (*Configuration, error) {
 
 	v.SetDefault("Token", "")
 	v.SetDefault("CheckPeriod", 5*time.Minute)
+	v.SetDefault("WatchNetwork", true)
+	v.SetDefault("ResyncPeriod", 0)
+	v.SetDefault("SyncWorkers", 4)
 	v.SetDefault("RequestTimeout", 10*time.Second)
 	v.SetDefault("IPv6", false)
+	v.SetDefault("StateFile", "")
+	v.SetDefault("VerifyPropagation", false)
+	v.SetDefault("ResolveBeforeUpdate", false)
+	v.SetDefault("PropagationTimeout", 2*time.Minute)
+	v.SetDefault("ProviderStrategy", "sequential")
+	v.SetDefault("Quorum", 0)
+	v.SetDefault("RaceStagger", 200*time.Millisecond)
+	v.SetDefault("FailureThreshold", 3)
+	v.SetDefault("ProviderCooldown", 10*time.Minute)
+	v.SetDefault("RotateProviders", false)
+	v.SetDefault("AllowPrivateIP", false)
 
 	if path != "" {
 		v.SetConfigFile(path)
@@ -120,6 +120,8 @@ func TestEnvVarsAreRead(t *testing.T) {
 	os.Setenv("DDNS_CHECKPERIOD", "60s")
 	os.Setenv("DDNS_REQUESTTIMEOUT", "12s")
 	os.Setenv("DDNS_IPV6", "true")
+	os.Setenv("DDNS_PROVIDERSTRATEGY", "quorum")
+	os.Setenv("DDNS_QUORUM", "2")
 	conf, err := NewConfiguration(fname)
 	is.NoErr(err)
 
@@ -127,4 +129,6 @@ func TestEnvVarsAreRead(t *testing.T) {
 	is.Equal(60*time.Second, conf.CheckPeriod)
 	is.Equal(12*time.Second, conf.RequestTimeout)
 	is.Equal(true, conf.IPv6)
+	is.Equal("quorum", conf.ProviderStrategy)
+	is.Equal(2, conf.Quorum)
 }
@@ -0,0 +1,244 @@
+package dnscheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skibish/ddns/do"
+)
+
+// ErrUnsupported is returned for record types which can't be checked.
+var ErrUnsupported = errors.New("record type is not supported")
+
+// defaultNameservers are used, when nameservers of the domain can't be discovered.
+var defaultNameservers = []string{
+	"ns1.digitalocean.com",
+	"ns2.digitalocean.com",
+	"ns3.digitalocean.com",
+}
+
+// defaultInterval is a delay between checks while waiting for propagation.
+const defaultInterval = 5 * time.Second
+
+// Checker checks what is published in DNS.
+type Checker interface {
+	// Published reports whether all nameservers serve the record data.
+	Published(ctx context.Context, domain string, r do.Record) (bool, error)
+	// Wait waits until all nameservers serve the record data.
+	Wait(ctx context.Context, domain string, r do.Record) error
+}
+
+type lookuper interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Authoritative queries authoritative nameservers of the domain directly,
+// so that the answers are not affected by caches of recursive resolvers.
+type Authoritative struct {
+	nameservers []string
+	timeout     time.Duration
+	interval    time.Duration
+
+	resolver func(nameserver string) lookuper
+	lookupNS func(ctx context.Context, domain string) ([]*net.NS, error)
+
+	mu         sync.Mutex
+	discovered map[string][]string
+	// fallback are the domains for which the fallback to defaultNameservers is logged.
+	fallback map[string]bool
+}
+
+// New return Authoritative checker.
+// If nameservers are empty, they are discovered from NS records of the domain.
+// Timeout limits every query.
+func New(nameservers []string, timeout time.Duration) *Authoritative {
+	return &Authoritative{
+		nameservers: nameservers,
+		timeout:     timeout,
+		interval:    defaultInterval,
+		resolver:    newResolver,
+		lookupNS:    net.DefaultResolver.LookupNS,
+		discovered:  make(map[string][]string),
+		fallback:    make(map[string]bool),
+	}
+}
+
+// newResolver return resolver which sends all queries to the nameserver.
+func newResolver(nameserver string) lookuper {
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(nameserver, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, nameserver)
+		},
+	}
+}
+
+// Published reports whether all nameservers serve the record data.
+// Only A, AAAA and TXT records are supported.
+func (a *Authoritative) Published(ctx context.Context, domain string, r do.Record) (bool, error) {
+	nameservers, err := a.nameserversOf(ctx, domain)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ns := range nameservers {
+		ok, err := a.servedBy(ctx, ns, domain, r)
+		if err != nil {
+			return false, fmt.Errorf("failed to query %s: %w", ns, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Wait checks nameservers in intervals until they all serve the record data,
+// or the context expires.
+func (a *Authoritative) Wait(ctx context.Context, domain string, r do.Record) error {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := a.Published(ctx, domain, r)
+		if errors.Is(err, ErrUnsupported) {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("record is not published: %w", err)
+			}
+			return errors.New("record is not published by all nameservers")
+		}
+	}
+}
+
+// servedBy reports whether the nameserver serves the record data.
+func (a *Authoritative) servedBy(ctx context.Context, ns, domain string, r do.Record) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	name := FQDN(r.Name, domain)
+	resolver := a.resolver(ns)
+
+	switch strings.ToUpper(r.Type) {
+	case "A", "AAAA":
+		network := "ip4"
+		if strings.EqualFold(r.Type, "AAAA") {
+			network = "ip6"
+		}
+
+		want, err := netip.ParseAddr(r.Data)
+		if err != nil {
+			return false, fmt.Errorf("invalid ip %q: %w", r.Data, err)
+		}
+
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return false, notFoundIsFalse(err)
+		}
+
+		for _, ip := range ips {
+			if got, ok := netip.AddrFromSlice(ip); ok && got.Unmap() == want.Unmap() {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return false, notFoundIsFalse(err)
+		}
+
+		for _, txt := range txts {
+			if txt == r.Data {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	default:
+		return false, ErrUnsupported
+	}
+}
+
+// nameserversOf return nameservers which should be queried for the domain.
+func (a *Authoritative) nameserversOf(ctx context.Context, domain string) ([]string, error) {
+	if len(a.nameservers) > 0 {
+		return a.nameservers, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if ns, ok := a.discovered[domain]; ok {
+		return ns, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	records, err := a.lookupNS(ctx, domain)
+	if err != nil || len(records) == 0 {
+		// the domain could be delegated to DigitalOcean just now,
+		// so the discovery is repeated next time, but logged only once
+		if !a.fallback[domain] {
+			a.fallback[domain] = true
+			if err == nil {
+				err = errors.New("no records")
+			}
+			log.Warnf("failed to discover nameservers of the domain %s, %s are queried: %s", domain, strings.Join(defaultNameservers, ", "), err)
+		}
+		return defaultNameservers, nil
+	}
+
+	ns := make([]string, 0, len(records))
+	for _, r := range records {
+		ns = append(ns, strings.TrimSuffix(r.Host, "."))
+	}
+	sort.Strings(ns)
+	a.discovered[domain] = ns
+
+	return ns, nil
+}
+
+// notFoundIsFalse treats missing records as not published yet, rather than an error.
+func notFoundIsFalse(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// FQDN return the fully qualified name of the record in the domain.
+func FQDN(name, domain string) string {
+	if name == "@" || name == "" {
+		return domain + "."
+	}
+
+	return name + "." + domain + "."
+}
@@ -0,0 +1,230 @@
+package dnscheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/skibish/ddns/do"
+)
+
+// fakeZone is a lookuper which serves records of the nameserver.
+type fakeZone struct {
+	mu    sync.Mutex
+	ips   map[string][]net.IP
+	txts  map[string][]string
+	err   error
+	names []string
+}
+
+func (f *fakeZone) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.names = append(f.names, host)
+	if f.err != nil {
+		return nil, f.err
+	}
+	ips, ok := f.ips[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+func (f *fakeZone) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.names = append(f.names, name)
+	if f.err != nil {
+		return nil, f.err
+	}
+	txts, ok := f.txts[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return txts, nil
+}
+
+func newFake(zones map[string]*fakeZone) *Authoritative {
+	a := New(nil, time.Second)
+	a.interval = 10 * time.Millisecond
+	a.resolver = func(ns string) lookuper {
+		return zones[ns]
+	}
+	a.lookupNS = func(ctx context.Context, domain string) ([]*net.NS, error) {
+		res := make([]*net.NS, 0, len(zones))
+		for ns := range zones {
+			res = append(res, &net.NS{Host: ns + "."})
+		}
+		return res, nil
+	}
+
+	return a
+}
+
+func TestPublished(t *testing.T) {
+	zone := func() *fakeZone {
+		return &fakeZone{
+			ips: map[string][]net.IP{
+				"www.example.com.": {net.ParseIP("45.45.45.45")},
+				"example.com.":     {net.ParseIP("2001:db8::1")},
+			},
+			txts: map[string][]string{
+				"demo.example.com.": {"v=spf1 -all", "ip=45.45.45.45"},
+			},
+		}
+	}
+
+	tcases := []struct {
+		tname    string
+		record   do.Record
+		stale    bool
+		err      error
+		expected bool
+		isErr    bool
+	}{
+		{tname: "ok A", record: do.Record{Type: "A", Name: "www", Data: "45.45.45.45"}, expected: true},
+		{tname: "ok AAAA apex", record: do.Record{Type: "AAAA", Name: "@", Data: "2001:DB8::1"}, expected: true},
+		{tname: "ok TXT", record: do.Record{Type: "TXT", Name: "demo", Data: "ip=45.45.45.45"}, expected: true},
+		{tname: "A differs", record: do.Record{Type: "A", Name: "www", Data: "66.66.66.66"}},
+		{tname: "TXT differs", record: do.Record{Type: "TXT", Name: "demo", Data: "ip=66.66.66.66"}},
+		{tname: "not found", record: do.Record{Type: "A", Name: "new", Data: "45.45.45.45"}},
+		{tname: "one nameserver is stale", record: do.Record{Type: "A", Name: "www", Data: "45.45.45.45"}, stale: true},
+		{tname: "query failed", record: do.Record{Type: "A", Name: "www", Data: "45.45.45.45"}, err: errors.New("timeout"), isErr: true},
+		{tname: "invalid ip", record: do.Record{Type: "A", Name: "www", Data: "oops"}, isErr: true},
+		{tname: "unsupported", record: do.Record{Type: "SRV", Name: "_sip._tcp", Data: "sip"}, isErr: true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			zones := map[string]*fakeZone{"ns1.example.net": zone(), "ns2.example.net": zone()}
+			zones["ns2.example.net"].err = tc.err
+			if tc.stale {
+				zones["ns2.example.net"].ips = map[string][]net.IP{}
+			}
+
+			ok, err := newFake(zones).Published(context.Background(), "example.com", tc.record)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+
+			is.NoErr(err)
+			is.Equal(ok, tc.expected)
+		})
+	}
+}
+
+func TestWait(t *testing.T) {
+	t.Run("ok propagated", func(t *testing.T) {
+		is := is.New(t)
+
+		zone := &fakeZone{ips: map[string][]net.IP{}}
+		a := newFake(map[string]*fakeZone{"ns1.example.net": zone})
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			zone.mu.Lock()
+			zone.ips["www.example.com."] = []net.IP{net.ParseIP("45.45.45.45")}
+			zone.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		err := a.Wait(ctx, "example.com", do.Record{Type: "A", Name: "www", Data: "45.45.45.45"})
+		is.NoErr(err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		zone := &fakeZone{ips: map[string][]net.IP{}}
+		a := newFake(map[string]*fakeZone{"ns1.example.net": zone})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := a.Wait(ctx, "example.com", do.Record{Type: "A", Name: "www", Data: "45.45.45.45"}); err == nil {
+			t.Fail() // should be error
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		is := is.New(t)
+
+		a := newFake(map[string]*fakeZone{"ns1.example.net": {}})
+
+		err := a.Wait(context.Background(), "example.com", do.Record{Type: "MX", Name: "@", Data: "mail"})
+		is.True(errors.Is(err, ErrUnsupported))
+	})
+}
+
+func TestNameservers(t *testing.T) {
+	t.Run("configured", func(t *testing.T) {
+		is := is.New(t)
+
+		a := New([]string{"ns1.example.net"}, time.Second)
+		ns, err := a.nameserversOf(context.Background(), "example.com")
+		is.NoErr(err)
+		is.Equal(ns, []string{"ns1.example.net"})
+	})
+
+	t.Run("discovered", func(t *testing.T) {
+		is := is.New(t)
+
+		var calls int
+		a := New(nil, time.Second)
+		a.lookupNS = func(ctx context.Context, domain string) ([]*net.NS, error) {
+			calls++
+			return []*net.NS{{Host: "ns2.example.net."}, {Host: "ns1.example.net."}}, nil
+		}
+
+		for n := 0; n < 2; n++ {
+			ns, err := a.nameserversOf(context.Background(), "example.com")
+			is.NoErr(err)
+			is.Equal(ns, []string{"ns1.example.net", "ns2.example.net"})
+		}
+		is.Equal(calls, 1) // discovered nameservers are cached
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		is := is.New(t)
+
+		hook := logtest.NewGlobal()
+		defer hook.Reset()
+
+		var calls int
+		a := New(nil, time.Second)
+		a.lookupNS = func(ctx context.Context, domain string) ([]*net.NS, error) {
+			calls++
+			return nil, errors.New("no such host")
+		}
+
+		for n := 0; n < 2; n++ {
+			ns, err := a.nameserversOf(context.Background(), "example.com")
+			is.NoErr(err)
+			is.Equal(ns, defaultNameservers)
+		}
+		is.Equal(calls, 2)             // discovery is repeated
+		is.Equal(len(hook.Entries), 1) // but the fallback is logged once
+		is.True(strings.Contains(hook.LastEntry().Message, "example.com"))
+	})
+}
+
+func TestFQDN(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(FQDN("@", "example.com"), "example.com.")
+	is.Equal(FQDN("www", "example.com"), "www.example.com.")
+}
@@ -0,0 +1,117 @@
+package ipprovider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// execProvider runs a command and takes IP from its output.
+type execProvider struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+
+	ipv6         bool
+	allowPrivate bool
+}
+
+func newExecProvider(cfg interface{}, timeout time.Duration) (*execProvider, error) {
+	var e execProvider
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &e,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a decoder: %w", err)
+	}
+
+	if err := d.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if e.Command == "" {
+		return nil, errors.New("command can't be empty")
+	}
+
+	if e.Timeout == 0 {
+		e.Timeout = timeout
+	}
+
+	return &e, nil
+}
+
+// ForceIPV6 .
+func (e *execProvider) ForceIPV6() {
+	e.ipv6 = true
+}
+
+// AllowPrivate allows to take addresses which are not globally routable from the output.
+func (e *execProvider) AllowPrivate() {
+	e.allowPrivate = true
+}
+
+// String returns the name of the provider.
+func (e *execProvider) String() string {
+	return e.Command
+}
+
+// GetIP get IP
+func (e *execProvider) GetIP(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("failed to run %s: %w: %s", e.Command, err, msg)
+		}
+		return "", fmt.Errorf("failed to run %s: %w", e.Command, err)
+	}
+
+	ip, err := findIP(stdout.String(), e.ipv6, e.allowPrivate)
+	if err != nil {
+		return "", fmt.Errorf("failed to find ip in the output of %s: %w", e.Command, err)
+	}
+
+	return ip, nil
+}
+
+// findIP returns the first address in the text which can be published,
+// so that loopback and LAN addresses listed e.g. by "ip addr" are skipped.
+// Addresses in CIDR notation (e.g. "inet 10.0.0.1/24") are recognized as well.
+func findIP(s string, ipv6, allowPrivate bool) (string, error) {
+	err := errors.New("no ip found")
+	for _, field := range strings.Fields(s) {
+		field, _, _ = strings.Cut(field, "/")
+
+		addr, parseErr := netip.ParseAddr(field)
+		if parseErr != nil {
+			continue
+		}
+		addr = addr.Unmap().WithZone("")
+
+		// every interface listing has them, but they are never the external address
+		if addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			continue
+		}
+
+		// the reason, why the last candidate is not suitable, is reported
+		if err = checkAddr(addr, ipv6, allowPrivate); err == nil {
+			return addr.String(), nil
+		}
+	}
+
+	return "", err
+}
@@ -0,0 +1,162 @@
+package ipprovider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// TestExecHelperProcess isn't a real test,
+// it is a command which is run by exec provider tests.
+func TestExecHelperProcess(t *testing.T) {
+	if os.Getenv("DDNS_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+
+	switch args[1] {
+	case "echo":
+		fmt.Print(args[2])
+	case "fail":
+		fmt.Fprint(os.Stderr, "something went wrong")
+		os.Exit(2)
+	case "sleep":
+		time.Sleep(10 * time.Second)
+	}
+	os.Exit(0)
+}
+
+func helperArgs(args ...string) []interface{} {
+	res := []interface{}{"-test.run=TestExecHelperProcess", "--"}
+	for _, a := range args {
+		res = append(res, a)
+	}
+	return res
+}
+
+func TestExecProviderNew(t *testing.T) {
+	is := is.New(t)
+
+	e, err := newExecProvider(map[string]interface{}{"command": "ip"}, 10*time.Second)
+	is.NoErr(err)
+	is.Equal(e.Timeout, 10*time.Second)
+
+	e, err = newExecProvider(map[string]interface{}{"command": "ip", "timeout": "2s"}, 10*time.Second)
+	is.NoErr(err)
+	is.Equal(e.Timeout, 2*time.Second)
+
+	if _, err := newExecProvider(map[string]interface{}{}, 10*time.Second); err == nil {
+		is.Fail() // should be error, command is empty
+	}
+
+	if _, err := newExecProvider(map[string]interface{}{"command": "ip", "timeout": "soon"}, 10*time.Second); err == nil {
+		is.Fail() // should be error, timeout is invalid
+	}
+}
+
+func TestExecProviderGetIP(t *testing.T) {
+	t.Setenv("DDNS_WANT_HELPER_PROCESS", "1")
+
+	tcases := []struct {
+		tname    string
+		args     []interface{}
+		ipv6     bool
+		private  bool
+		timeout  time.Duration
+		expected string
+		isErr    bool
+	}{
+		{
+			tname:    "ok",
+			args:     helperArgs("echo", "45.45.45.45\n"),
+			expected: "45.45.45.45",
+		},
+		{
+			tname:    "ok ip addr output",
+			args:     helperArgs("echo", "1: lo    inet 127.0.0.1/8 scope host lo\n2: eth0    inet 192.168.1.5/24 brd 192.168.1.255 scope global eth0\n3: ppp0    inet 45.45.45.45 peer 10.0.0.1/32 scope global ppp0\n"),
+			expected: "45.45.45.45",
+		},
+		{
+			tname:    "ok private allowed",
+			args:     helperArgs("echo", "1: lo    inet 127.0.0.1/8 scope host lo\n2: eth0    inet 192.168.1.5/24 brd 192.168.1.255 scope global eth0\n"),
+			private:  true,
+			expected: "192.168.1.5",
+		},
+		{
+			tname: "only private",
+			args:  helperArgs("echo", "1: lo    inet 127.0.0.1/8 scope host lo\n2: eth0    inet 192.168.1.5/24 brd 192.168.1.255 scope global eth0\n"),
+			isErr: true,
+		},
+		{
+			tname:    "ok ipv6",
+			args:     helperArgs("echo", "inet 192.168.1.5/24\ninet6 fe80::1/64 scope link\ninet6 2a00:1450:0:0::5/64 scope global\n"),
+			ipv6:     true,
+			expected: "2a00:1450::5",
+		},
+		{
+			tname: "no ip",
+			args:  helperArgs("echo", "nothing to see here"),
+			isErr: true,
+		},
+		{
+			tname: "wrong family",
+			args:  helperArgs("echo", "45.45.45.45"),
+			ipv6:  true,
+			isErr: true,
+		},
+		{
+			tname: "exit code",
+			args:  helperArgs("fail"),
+			isErr: true,
+		},
+		{
+			tname:   "timeout",
+			args:    helperArgs("sleep"),
+			timeout: 1 * time.Second,
+			isErr:   true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			// starting the test binary is slow under the race detector
+			timeout := 10 * time.Second
+			if tc.timeout != 0 {
+				timeout = tc.timeout
+			}
+
+			e, err := newExecProvider(map[string]interface{}{
+				"command": os.Args[0],
+				"args":    tc.args,
+			}, timeout)
+			is.NoErr(err)
+
+			if tc.ipv6 {
+				e.ForceIPV6()
+			}
+			if tc.private {
+				e.AllowPrivate()
+			}
+
+			ip, err := e.GetIP(context.Background())
+			if tc.isErr {
+				if err == nil {
+					t.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(ip, tc.expected)
+		})
+	}
+}
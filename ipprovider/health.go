@@ -0,0 +1,174 @@
+package ipprovider
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults of the provider circuit breaker.
+const (
+	defaultFailureThreshold = 3
+	defaultCooldown         = 10 * time.Minute
+)
+
+// ProviderHealth is a snapshot of provider health.
+type ProviderHealth struct {
+	Name                string
+	Successes           int
+	Failures            int
+	ConsecutiveFailures int
+	// Latency is an exponentially weighted average latency of successful requests.
+	Latency   time.Duration
+	LastError string
+	// DemotedUntil is set, while the provider is demoted
+	// to the end of the chain after consecutive failures.
+	DemotedUntil time.Time
+}
+
+// SuccessRate return the share of successful requests.
+func (h ProviderHealth) SuccessRate() float64 {
+	total := h.Successes + h.Failures
+	if total == 0 {
+		return 0
+	}
+
+	return float64(h.Successes) / float64(total)
+}
+
+func (h ProviderHealth) String() string {
+	total := h.Successes + h.Failures
+	if total == 0 {
+		return fmt.Sprintf("%s has not been asked yet", h.Name)
+	}
+
+	s := fmt.Sprintf("%s: %.0f%% of %d requests succeeded, average latency %s",
+		h.Name, h.SuccessRate()*100, total, h.Latency.Round(time.Millisecond))
+	if h.Demoted(time.Now()) {
+		s += fmt.Sprintf(", demoted until %s", h.DemotedUntil.Format(time.RFC3339))
+	}
+	if h.LastError != "" {
+		s += ", last error: " + h.LastError
+	}
+
+	return s
+}
+
+// Demoted reports whether the provider is demoted at the moment.
+func (h ProviderHealth) Demoted(now time.Time) bool {
+	return now.Before(h.DemotedUntil)
+}
+
+// healthOf return health record of the provider, creating it if needed.
+// Must be called with mu held.
+func (i *IPProvider) healthOf(p ipProvider) *ProviderHealth {
+	if i.health == nil {
+		i.health = make(map[ipProvider]*ProviderHealth)
+	}
+
+	h, ok := i.health[p]
+	if !ok {
+		h = &ProviderHealth{Name: p.String()}
+		i.health[p] = h
+	}
+
+	return h
+}
+
+// recordSuccess updates provider health after a successful request.
+func (i *IPProvider) recordSuccess(p ipProvider, latency time.Duration) {
+	i.mu.Lock()
+
+	h := i.healthOf(p)
+	recovered := i.failureThreshold > 0 && h.ConsecutiveFailures >= i.failureThreshold
+	if recovered {
+		log.Infof("ip provider %s recovered after %d failures", h.Name, h.ConsecutiveFailures)
+	}
+
+	h.Successes++
+	h.ConsecutiveFailures = 0
+	h.DemotedUntil = time.Time{}
+
+	if h.Latency == 0 {
+		h.Latency = latency
+	} else {
+		h.Latency = (h.Latency*4 + latency) / 5
+	}
+	snapshot := *h
+
+	i.mu.Unlock()
+
+	if recovered && i.onRecovered != nil {
+		i.onRecovered(snapshot)
+	}
+}
+
+// recordFailure updates provider health after a failed request
+// and demotes the provider, if it keeps failing.
+func (i *IPProvider) recordFailure(p ipProvider, err error) {
+	i.mu.Lock()
+
+	h := i.healthOf(p)
+	h.Failures++
+	h.ConsecutiveFailures++
+	h.LastError = err.Error()
+
+	now := time.Now()
+	demoted := i.failureThreshold > 0 && h.ConsecutiveFailures >= i.failureThreshold && !h.Demoted(now)
+	if demoted {
+		h.DemotedUntil = now.Add(i.cooldown)
+		log.Warnf("ip provider %s is demoted for %s after %d consecutive failures (success rate %.0f%%), last error: %s",
+			h.Name, i.cooldown, h.ConsecutiveFailures, h.SuccessRate()*100, h.LastError)
+	}
+	snapshot := *h
+
+	i.mu.Unlock()
+
+	if demoted && i.onDegraded != nil {
+		i.onDegraded(snapshot)
+	}
+}
+
+// order return providers in the order they should be asked:
+// healthy providers first (rotated, if enabled), then demoted ones as the last resort.
+func (i *IPProvider) order() []ipProvider {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]ipProvider, 0, len(i.providers))
+	demoted := make([]ipProvider, 0)
+	for _, p := range i.providers {
+		if i.healthOf(p).Demoted(now) {
+			demoted = append(demoted, p)
+			continue
+		}
+		healthy = append(healthy, p)
+	}
+
+	offset := 0
+	if i.rotate && len(healthy) > 0 {
+		offset = i.calls % len(healthy)
+	}
+	i.calls++
+
+	res := make([]ipProvider, 0, len(i.providers))
+	res = append(res, healthy[offset:]...)
+	res = append(res, healthy[:offset]...)
+
+	return append(res, demoted...)
+}
+
+// Health return health of providers in the configured order.
+func (i *IPProvider) Health() []ProviderHealth {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	res := make([]ProviderHealth, 0, len(i.providers))
+	for _, p := range i.providers {
+		res = append(res, *i.healthOf(p))
+	}
+
+	return res
+}
@@ -0,0 +1,198 @@
+package ipprovider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestHealthDemotion(t *testing.T) {
+	is := is.New(t)
+
+	flaky := &staticProvider{name: "flaky", err: errors.New("oops")}
+	stable := &staticProvider{name: "stable", ip: "45.45.45.45"}
+
+	var degraded, recovered []ProviderHealth
+	ipp := &IPProvider{
+		providers:        []ipProvider{flaky, stable},
+		strategy:         StrategySequential,
+		failureThreshold: 2,
+		cooldown:         time.Hour,
+		onDegraded: func(h ProviderHealth) {
+			degraded = append(degraded, h)
+		},
+		onRecovered: func(h ProviderHealth) {
+			recovered = append(recovered, h)
+		},
+	}
+
+	for n := 0; n < 2; n++ {
+		ip, err := ipp.GetIP(context.Background())
+		is.NoErr(err)
+		is.Equal(ip, "45.45.45.45")
+	}
+
+	is.Equal(len(degraded), 1)
+	is.Equal(degraded[0].Name, "flaky")
+	is.Equal(degraded[0].LastError, "oops")
+
+	health := ipp.Health()
+	is.Equal(health[0].Name, "flaky")
+	is.Equal(health[0].Failures, 2)
+	is.Equal(health[0].ConsecutiveFailures, 2)
+	is.Equal(health[0].LastError, "oops")
+	is.True(health[0].Demoted(time.Now()))
+	is.Equal(health[0].SuccessRate(), 0.0)
+	is.Equal(health[1].Successes, 2)
+	is.Equal(health[1].SuccessRate(), 1.0)
+	is.True(!health[1].Demoted(time.Now()))
+
+	// demoted provider is asked last
+	is.Equal(ipp.order(), []ipProvider{stable, flaky})
+
+	_, err := ipp.GetIP(context.Background())
+	is.NoErr(err)
+	is.Equal(ipp.Health()[0].Failures, 2) // flaky was not asked
+
+	// demoted provider is still the last resort
+	stable.err = errors.New("oops")
+	stable.ip = ""
+	flaky.err = nil
+	flaky.ip = "45.45.45.45"
+
+	ip, err := ipp.GetIP(context.Background())
+	is.NoErr(err)
+	is.Equal(ip, "45.45.45.45")
+
+	// and recovers after success
+	is.Equal(len(recovered), 1)
+	is.Equal(recovered[0].Name, "flaky")
+	health = ipp.Health()
+	is.Equal(health[0].ConsecutiveFailures, 0)
+	is.True(!health[0].Demoted(time.Now()))
+}
+
+func TestHealthCooldown(t *testing.T) {
+	is := is.New(t)
+
+	flaky := &staticProvider{name: "flaky", err: errors.New("oops")}
+	stable := &staticProvider{name: "stable", ip: "45.45.45.45"}
+
+	ipp := &IPProvider{
+		providers:        []ipProvider{flaky, stable},
+		failureThreshold: 1,
+		cooldown:         time.Millisecond,
+	}
+
+	_, err := ipp.GetIP(context.Background())
+	is.NoErr(err)
+
+	time.Sleep(5 * time.Millisecond)
+	is.Equal(ipp.order(), []ipProvider{flaky, stable})
+}
+
+func TestHealthRotate(t *testing.T) {
+	is := is.New(t)
+
+	a := &staticProvider{name: "a", ip: "45.45.45.45"}
+	b := &staticProvider{name: "b", ip: "45.45.45.45"}
+	c := &staticProvider{name: "c", ip: "45.45.45.45"}
+
+	ipp := &IPProvider{
+		providers: []ipProvider{a, b, c},
+		rotate:    true,
+	}
+
+	is.Equal(ipp.order(), []ipProvider{a, b, c})
+	is.Equal(ipp.order(), []ipProvider{b, c, a})
+	is.Equal(ipp.order(), []ipProvider{c, a, b})
+	is.Equal(ipp.order(), []ipProvider{a, b, c})
+
+	ipp.rotate = false
+	is.Equal(ipp.order(), []ipProvider{a, b, c})
+	is.Equal(ipp.order(), []ipProvider{a, b, c})
+}
+
+func TestHealthCanceledIsNotFailure(t *testing.T) {
+	is := is.New(t)
+
+	hanging := &staticProvider{name: "hanging", ip: "66.66.66.66", delay: 10 * time.Second}
+	ipp := &IPProvider{
+		providers: []ipProvider{
+			hanging,
+			&staticProvider{name: "fast", ip: "45.45.45.45"},
+		},
+		strategy:         StrategyRace,
+		stagger:          10 * time.Millisecond,
+		failureThreshold: 1,
+		cooldown:         time.Hour,
+	}
+
+	_, err := ipp.GetIP(context.Background())
+	is.NoErr(err)
+
+	time.Sleep(50 * time.Millisecond)
+	is.Equal(ipp.Health()[0].Failures, 0)
+}
+
+func TestHealthLatency(t *testing.T) {
+	is := is.New(t)
+
+	p := &staticProvider{name: "a", ip: "45.45.45.45"}
+	ipp := &IPProvider{providers: []ipProvider{p}}
+
+	ipp.recordSuccess(p, 100*time.Millisecond)
+	is.Equal(ipp.Health()[0].Latency, 100*time.Millisecond)
+
+	ipp.recordSuccess(p, 600*time.Millisecond)
+	is.Equal(ipp.Health()[0].Latency, 200*time.Millisecond)
+}
+
+func TestHealthFailureThreshold(t *testing.T) {
+	zero, five := 0, 5
+
+	tcases := []struct {
+		tname     string
+		threshold *int
+		expected  int
+	}{
+		{"default", nil, defaultFailureThreshold},
+		{"disabled", &zero, 0},
+		{"configured", &five, 5},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			ipp, err := New(Config{Timeout: time.Second, FailureThreshold: tc.threshold})
+			is.NoErr(err)
+			is.Equal(ipp.(*IPProvider).failureThreshold, tc.expected)
+		})
+	}
+
+	t.Run("disabled never demotes", func(t *testing.T) {
+		is := is.New(t)
+
+		flaky := &staticProvider{name: "flaky", err: errors.New("oops")}
+		ipp := &IPProvider{providers: []ipProvider{flaky}, strategy: StrategySequential, cooldown: time.Hour}
+
+		for n := 0; n < 5; n++ {
+			_, err := ipp.GetIP(context.Background())
+			is.True(err != nil)
+		}
+		is.True(!ipp.Health()[0].Demoted(time.Now()))
+	})
+}
+
+func TestHealthString(t *testing.T) {
+	is := is.New(t)
+
+	h := ProviderHealth{Name: "flaky", Successes: 1, Failures: 3, Latency: 1500 * time.Millisecond, LastError: "oops", DemotedUntil: time.Now().Add(time.Hour)}
+	is.True(strings.HasPrefix(h.String(), "flaky: 25% of 4 requests succeeded, average latency 1.5s, demoted until "))
+	is.True(strings.HasSuffix(h.String(), ", last error: oops"))
+}
@@ -0,0 +1,214 @@
+package ipprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/skibish/ddns/misc"
+)
+
+// Response formats supported by httpProvider.
+const (
+	formatPlain = "plain"
+	formatJSON  = "json"
+	formatRegex = "regex"
+)
+
+// Limits of the response body, an IP fits in a few bytes,
+// while JSON and pages for regex format can carry more around it.
+const (
+	maxPlainBodySize = 256
+	maxBodySize      = 16 << 10
+)
+
+// httpProvider is a configurable provider which gets IP
+// from any HTTP endpoint that echoes the address back.
+type httpProvider struct {
+	URL     string
+	URL6    string `mapstructure:"url6"`
+	Headers map[string]string
+	Format  string
+	Path    string
+	Regex   string
+
+	c   *http.Client
+	url string
+	// name is the url without credentials and query, which could contain tokens.
+	name    string
+	re      *regexp.Regexp
+	timeout time.Duration
+}
+
+func newHTTPProvider(cfg interface{}, timeout time.Duration) (*httpProvider, error) {
+	var h httpProvider
+	if err := mapstructure.Decode(cfg, &h); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if h.URL == "" {
+		return nil, errors.New("url can't be empty")
+	}
+
+	h.Format = strings.ToLower(h.Format)
+	switch h.Format {
+	case "":
+		h.Format = formatPlain
+	case formatPlain:
+	case formatJSON:
+		if h.Path == "" {
+			return nil, errors.New("path can't be empty for json format")
+		}
+	case formatRegex:
+		if h.Regex == "" {
+			return nil, errors.New("regex can't be empty for regex format")
+		}
+		re, err := regexp.Compile(h.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex: %w", err)
+		}
+		h.re = re
+	default:
+		return nil, fmt.Errorf("format %s is not supported", h.Format)
+	}
+
+	h.c = &http.Client{}
+	h.url = h.URL
+	h.name = redactURL(h.URL)
+	h.timeout = timeout
+
+	return &h, nil
+}
+
+// ForceIPV6 switches to IPv6 URL, if it is set.
+func (h *httpProvider) ForceIPV6() {
+	if h.URL6 != "" {
+		h.url = h.URL6
+		h.name = redactURL(h.URL6)
+	}
+}
+
+// String returns the name of the provider.
+func (h *httpProvider) String() string {
+	return h.name
+}
+
+// redactURL strips user info, query and fragment from the url,
+// so that the name of the provider doesn't leak tokens to logs and notifications.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "http"
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+
+	return u.String()
+}
+
+// GetIP get IP
+func (h *httpProvider) GetIP(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a request: %w", err)
+	}
+
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to do a request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if !misc.Success(resp.StatusCode) {
+		return "", fmt.Errorf("status code is not in success range: %d", resp.StatusCode)
+	}
+
+	limit := int64(maxBodySize)
+	if h.Format == formatPlain {
+		limit = maxPlainBodySize
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the body of the response: %w", err)
+	}
+
+	if int64(len(b)) > limit {
+		return "", fmt.Errorf("response is larger than %d bytes", limit)
+	}
+
+	return h.parse(b)
+}
+
+// parse extracts IP from the response body according to the format.
+func (h *httpProvider) parse(b []byte) (string, error) {
+	switch h.Format {
+	case formatJSON:
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return "", fmt.Errorf("failed to decode the response: %w", err)
+		}
+		return lookupJSONPath(v, h.Path)
+	case formatRegex:
+		m := h.re.FindSubmatch(b)
+		if m == nil {
+			return "", errors.New("response does not match the regex")
+		}
+		// prefer the first capture group over the whole match
+		if len(m) > 1 {
+			return strings.TrimSpace(string(m[1])), nil
+		}
+		return strings.TrimSpace(string(m[0])), nil
+	default:
+		return strings.TrimSpace(string(b)), nil
+	}
+}
+
+// lookupJSONPath walks through decoded JSON following dot separated path,
+// where numeric segments are array indexes, e.g. "data.addresses.0.ip".
+func lookupJSONPath(v interface{}, path string) (string, error) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("key %s not found in the response", key)
+			}
+			v = next
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return "", fmt.Errorf("index %s is out of range in the response", key)
+			}
+			v = node[idx]
+		default:
+			return "", fmt.Errorf("key %s not found in the response", key)
+		}
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("value of %s is not a string", path)
+	}
+
+	return strings.TrimSpace(s), nil
+}
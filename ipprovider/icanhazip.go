@@ -31,6 +31,11 @@ func (i *icanhazip) ForceIPV6() {
 	i.url = "https://ipv6.icanhazip.com"
 }
 
+// String returns the name of the provider.
+func (i *icanhazip) String() string {
+	return "icanhazip"
+}
+
 func (i *icanhazip) GetIP(ctx context.Context) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, i.timeout)
 	defer cancel()
@@ -35,6 +35,11 @@ func (i *ipify) ForceIPV6() {
 	i.url = "https://api6.ipify.org/?format=json"
 }
 
+// String returns the name of the provider.
+func (i *ipify) String() string {
+	return "ipify"
+}
+
 // GetIP get ip
 func (i *ipify) GetIP(ctx context.Context) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, i.timeout)
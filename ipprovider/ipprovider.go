@@ -3,57 +3,434 @@ package ipprovider
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 )
 
+// Strategies of querying providers.
+const (
+	// StrategySequential asks providers one by one
+	// and trusts the first one which responds.
+	StrategySequential = "sequential"
+	// StrategyQuorum asks all providers concurrently
+	// and accepts IP only if enough of them agree.
+	StrategyQuorum = "quorum"
+	// StrategyRace asks providers concurrently, starting them one after
+	// another with a small stagger, and trusts the first valid answer.
+	StrategyRace = "race"
+)
+
+// defaultStagger is a delay between provider starts in StrategyRace.
+const defaultStagger = 200 * time.Millisecond
+
 // Provider is an interface that should
 // be implemented by all IP providers.
 type Provider interface {
 	GetIP(context.Context) (string, error)
 }
 
+// HealthReporter reports health of the providers.
+type HealthReporter interface {
+	Health() []ProviderHealth
+}
+
 type ipProvider interface {
 	Provider
+	fmt.Stringer
 	ForceIPV6()
 }
 
+// privateAllower is implemented by providers, which pick an address
+// among several ones and need to know whether private ones are fine.
+type privateAllower interface {
+	AllowPrivate()
+}
+
+// Config is a configuration of IPProvider.
+type Config struct {
+	IPv6    bool
+	Timeout time.Duration
+	// Providers are configurations of providers,
+	// if empty, the default chain of public echo services is used.
+	Providers []map[string]interface{}
+	// Strategy defaults to StrategySequential.
+	Strategy string
+	// Quorum is a minimum number of providers which should agree on IP
+	// in StrategyQuorum, defaults to the majority of providers.
+	Quorum int
+	// Stagger is a delay before the next provider is started in StrategyRace,
+	// if the previous one has not responded yet, defaults to 200ms.
+	Stagger time.Duration
+	// FailureThreshold is a number of consecutive failures after which
+	// a provider is demoted to the end of the chain, defaults to 3 if nil.
+	// Zero disables demotion.
+	FailureThreshold *int
+	// Cooldown is for how long a provider is demoted, defaults to 10 minutes.
+	Cooldown time.Duration
+	// Rotate rotates the order of healthy providers on every request,
+	// so that the load is spread between them.
+	Rotate bool
+	// AllowPrivate allows addresses which are not globally routable:
+	// private, loopback, link-local and CGNAT (100.64.0.0/10).
+	AllowPrivate bool
+	// OnDegraded is called, when a provider is demoted.
+	OnDegraded func(ProviderHealth)
+	// OnRecovered is called, when a demoted provider succeeds again.
+	OnRecovered func(ProviderHealth)
+}
+
 // IPProvider struct is IP provider service.
 type IPProvider struct {
-	providers []ipProvider
+	providers        []ipProvider
+	strategy         string
+	quorum           int
+	stagger          time.Duration
+	failureThreshold int
+	cooldown         time.Duration
+	rotate           bool
+	ipv6             bool
+	allowPrivate     bool
+	onDegraded       func(ProviderHealth)
+	onRecovered      func(ProviderHealth)
+
+	mu     sync.Mutex
+	health map[ipProvider]*ProviderHealth
+	calls  int
+}
+
+type providerType struct {
+	Type string
 }
 
 // New return new IPProvider instance.
-func New(ipv6 bool, timeout time.Duration) Provider {
+func New(cfg Config) (Provider, error) {
 	providers := []ipProvider{
-		newIcanhazip(timeout),
-		newWtfismyip(timeout),
-		newIpify(timeout),
+		newIcanhazip(cfg.Timeout),
+		newWtfismyip(cfg.Timeout),
+		newIpify(cfg.Timeout),
+	}
+
+	if len(cfg.Providers) > 0 {
+		providers = make([]ipProvider, 0, len(cfg.Providers))
+		for _, pcfg := range cfg.Providers {
+			p, err := newProvider(pcfg, cfg.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, p)
+		}
 	}
 
-	if ipv6 {
+	if cfg.IPv6 {
 		for _, p := range providers {
 			p.ForceIPV6()
 		}
 	}
 
-	return &IPProvider{
-		providers: providers,
+	if cfg.AllowPrivate {
+		for _, p := range providers {
+			if a, ok := p.(privateAllower); ok {
+				a.AllowPrivate()
+			}
+		}
 	}
+
+	ipp := &IPProvider{
+		providers:        providers,
+		strategy:         strings.ToLower(cfg.Strategy),
+		quorum:           cfg.Quorum,
+		stagger:          cfg.Stagger,
+		failureThreshold: defaultFailureThreshold,
+		cooldown:         cfg.Cooldown,
+		rotate:           cfg.Rotate,
+		ipv6:             cfg.IPv6,
+		allowPrivate:     cfg.AllowPrivate,
+		onDegraded:       cfg.OnDegraded,
+		onRecovered:      cfg.OnRecovered,
+	}
+
+	if cfg.FailureThreshold != nil {
+		ipp.failureThreshold = *cfg.FailureThreshold
+	}
+
+	if ipp.cooldown == 0 {
+		ipp.cooldown = defaultCooldown
+	}
+
+	switch ipp.strategy {
+	case "":
+		ipp.strategy = StrategySequential
+	case StrategySequential:
+	case StrategyQuorum:
+		if ipp.quorum == 0 {
+			ipp.quorum = len(providers)/2 + 1
+		}
+		if ipp.quorum < 1 || ipp.quorum > len(providers) {
+			return nil, fmt.Errorf("quorum should be between 1 and %d", len(providers))
+		}
+	case StrategyRace:
+		if ipp.stagger == 0 {
+			ipp.stagger = defaultStagger
+		}
+	default:
+		return nil, fmt.Errorf("strategy %s does not exists", cfg.Strategy)
+	}
+
+	return ipp, nil
 }
 
-// GetIP return IP from the first successful source.
+// newProvider initializes a provider from its configuration.
+func newProvider(cfg interface{}, timeout time.Duration) (ipProvider, error) {
+	var pt providerType
+	if err := mapstructure.Decode(cfg, &pt); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(pt.Type) {
+	case "icanhazip":
+		return newIcanhazip(timeout), nil
+	case "wtfismyip":
+		return newWtfismyip(timeout), nil
+	case "ipify":
+		return newIpify(timeout), nil
+	case "http":
+		return newHTTPProvider(cfg, timeout)
+	case "exec":
+		return newExecProvider(cfg, timeout)
+	case "upnp":
+		return newUPnP(cfg, timeout)
+	case "natpmp":
+		return newNATPMP(cfg, timeout)
+	case "pcp":
+		return newPCP(cfg, timeout)
+	default:
+		return nil, fmt.Errorf("ip provider %s does not exists", pt.Type)
+	}
+}
+
+// GetIP return IP according to the configured strategy.
 func (i *IPProvider) GetIP(ctx context.Context) (string, error) {
-	for _, p := range i.providers {
-		ip, err := p.GetIP(ctx)
+	switch i.strategy {
+	case StrategyQuorum:
+		return i.getIPQuorum(ctx)
+	case StrategyRace:
+		return i.getIPRace(ctx)
+	default:
+		return i.getIPSequential(ctx)
+	}
+}
+
+// getIPSequential return IP from the first successful source.
+func (i *IPProvider) getIPSequential(ctx context.Context) (string, error) {
+	for _, p := range i.order() {
+		ip, err := i.query(ctx, p)
 		if err != nil {
-			log.Warn(err)
+			log.Warnf("%s: %s", p, err)
+			continue
 		}
-		if ip != "" {
-			return ip, nil
+
+		return ip, nil
+	}
+
+	return "", errors.New("failed to get ip from providers")
+}
+
+// getIPQuorum asks all providers concurrently
+// and return IP which at least quorum of them agree on.
+func (i *IPProvider) getIPQuorum(ctx context.Context) (string, error) {
+	type result struct {
+		provider string
+		ip       string
+		err      error
+	}
+
+	results := make(chan result, len(i.providers))
+	for _, p := range i.order() {
+		go func(p ipProvider) {
+			ip, err := i.query(ctx, p)
+			results <- result{provider: p.String(), ip: ip, err: err}
+		}(p)
+	}
+
+	votes := make(map[string][]string)
+	for range i.providers {
+		r := <-results
+		if r.err != nil {
+			log.Warnf("%s: %s", r.provider, r.err)
+			continue
+		}
+		votes[r.ip] = append(votes[r.ip], r.provider)
+	}
+
+	ips := make([]string, 0, len(votes))
+	for ip := range votes {
+		ips = append(ips, ip)
+	}
+	// most voted IP goes first
+	sort.Slice(ips, func(a, b int) bool {
+		if len(votes[ips[a]]) != len(votes[ips[b]]) {
+			return len(votes[ips[a]]) > len(votes[ips[b]])
+		}
+		return ips[a] < ips[b]
+	})
+
+	if len(ips) > 1 {
+		opinions := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			sort.Strings(votes[ip])
+			opinions = append(opinions, fmt.Sprintf("%s (%s)", ip, strings.Join(votes[ip], ", ")))
+		}
+		log.Warnf("providers disagree on ip: %s", strings.Join(opinions, "; "))
+	}
+
+	if len(ips) == 0 {
+		return "", errors.New("failed to get ip from providers")
+	}
+
+	if len(ips) > 1 && len(votes[ips[0]]) == len(votes[ips[1]]) {
+		return "", fmt.Errorf("providers are split evenly between %s and %s", ips[0], ips[1])
+	}
+
+	if len(votes[ips[0]]) < i.quorum {
+		return "", fmt.Errorf("quorum is not reached, %d of %d required providers agree on %s", len(votes[ips[0]]), i.quorum, ips[0])
+	}
+
+	return ips[0], nil
+}
+
+// getIPRace starts providers one after another with a stagger,
+// return the first valid IP and cancel the rest.
+// If a provider fails, the next one is started without waiting.
+func (i *IPProvider) getIPRace(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		provider string
+		ip       string
+		err      error
+	}
+
+	// buffered, so that late providers don't block after return
+	results := make(chan result, len(i.providers))
+
+	providers := i.order()
+	next := 0
+	start := func() {
+		p := providers[next]
+		next++
+
+		go func() {
+			ip, err := i.query(ctx, p)
+			results <- result{provider: p.String(), ip: ip, err: err}
+		}()
+	}
+
+	start()
+	running := 1
+
+	timer := time.NewTimer(i.stagger)
+	defer timer.Stop()
+
+	for running > 0 {
+		select {
+		case r := <-results:
+			running--
+			if r.err == nil {
+				return r.ip, nil
+			}
+			log.Warnf("%s: %s", r.provider, r.err)
+
+			if next < len(providers) {
+				start()
+				running++
+				timer.Reset(i.stagger)
+			}
+		case <-timer.C:
+			if next < len(providers) {
+				start()
+				running++
+				timer.Reset(i.stagger)
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
 		}
 	}
 
 	return "", errors.New("failed to get ip from providers")
 }
+
+// query asks the provider for IP, validates it and records provider health.
+func (i *IPProvider) query(ctx context.Context, p ipProvider) (string, error) {
+	started := time.Now()
+	ip, err := p.GetIP(ctx)
+	if err == nil {
+		ip, err = i.validate(ip)
+	}
+
+	if err != nil {
+		// provider was canceled, it is not its fault
+		if ctx.Err() != nil {
+			return "", err
+		}
+		i.recordFailure(p, err)
+		return "", err
+	}
+
+	i.recordSuccess(p, time.Since(started))
+
+	return ip, nil
+}
+
+// cgnat is the shared address space used by carrier-grade NAT (RFC 6598).
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// validate checks that ip is an address of the requested family
+// which can be published, and return it in the canonical form.
+func (i *IPProvider) validate(ip string) (string, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return "", fmt.Errorf("invalid ip %q", truncate(ip, 64))
+	}
+	addr = addr.Unmap().WithZone("")
+
+	if err := checkAddr(addr, i.ipv6, i.allowPrivate); err != nil {
+		return "", err
+	}
+
+	return addr.String(), nil
+}
+
+// checkAddr checks that addr is of the requested family and can be published.
+func checkAddr(addr netip.Addr, ipv6, allowPrivate bool) error {
+	if ipv6 != addr.Is6() {
+		return fmt.Errorf("ip %s is of the wrong family", addr)
+	}
+
+	if addr.IsUnspecified() || addr.IsMulticast() {
+		return fmt.Errorf("ip %s is not an unicast address", addr)
+	}
+
+	if !allowPrivate && (addr.IsPrivate() || addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() || cgnat.Contains(addr)) {
+		return fmt.Errorf("ip %s is not a public address", addr)
+	}
+
+	return nil
+}
+
+// truncate shortens s to n bytes, so that error pages don't flood the logs.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n] + "..."
+}
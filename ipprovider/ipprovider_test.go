@@ -2,11 +2,13 @@ package ipprovider
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -38,10 +40,53 @@ func TestGetIP(t *testing.T) {
 	t.Run("ipv6", func(t *testing.T) {
 		is := is.New(t)
 
-		ipp := New(true, 1*time.Second)
+		ipp, err := New(Config{IPv6: true, Timeout: 1 * time.Second})
+		is.NoErr(err)
 		is.True(strings.Contains(ipp.(*IPProvider).providers[0].(*icanhazip).url, "6"))
 	})
 
+	t.Run("configured", func(t *testing.T) {
+		is := is.New(t)
+
+		ipp, err := New(Config{
+			Timeout: 1 * time.Second,
+			Providers: []map[string]interface{}{
+				{"type": "natpmp", "gateway": "192.168.1.1"},
+				{"type": "UPnP"},
+				{"type": "ipify"},
+				{"type": "pcp", "gateway": "192.168.1.1"},
+			},
+		})
+		is.NoErr(err)
+
+		providers := ipp.(*IPProvider).providers
+		is.Equal(len(providers), 4)
+		_, ok := providers[0].(*natpmp)
+		is.True(ok)
+		_, ok = providers[1].(*upnp)
+		is.True(ok)
+		_, ok = providers[2].(*ipify)
+		is.True(ok)
+		_, ok = providers[3].(*pcp)
+		is.True(ok)
+	})
+
+	t.Run("configured fail", func(t *testing.T) {
+		tcases := []Config{
+			{Providers: []map[string]interface{}{{"type": "zzz"}}},
+			{Providers: []map[string]interface{}{{"type": "natpmp"}}},
+			{Strategy: "zzz"},
+			{Strategy: StrategyQuorum, Quorum: 4},
+			{Strategy: StrategyQuorum, Quorum: -1},
+		}
+
+		for _, cfg := range tcases {
+			if _, err := New(cfg); err == nil {
+				t.Fail() // should be error
+			}
+		}
+	})
+
 	tcases := []struct {
 		tname    string
 		response string
@@ -56,7 +101,8 @@ func TestGetIP(t *testing.T) {
 		t.Run(tc.tname, func(t *testing.T) {
 			is := is.New(t)
 
-			ipp := New(false, 1*time.Second)
+			ipp, err := New(Config{Timeout: 1 * time.Second})
+			is.NoErr(err)
 
 			url, close := httpHelper(t, tc.response, nil, http.StatusOK)
 			defer close()
@@ -82,3 +128,275 @@ func TestGetIP(t *testing.T) {
 		})
 	}
 }
+
+// staticProvider is a provider which always responds the same,
+// optionally after a delay.
+type staticProvider struct {
+	name     string
+	ip       string
+	err      error
+	delay    time.Duration
+	canceled atomic.Bool
+}
+
+func (s *staticProvider) GetIP(ctx context.Context) (string, error) {
+	select {
+	case <-time.After(s.delay):
+		return s.ip, s.err
+	case <-ctx.Done():
+		s.canceled.Store(true)
+		return "", ctx.Err()
+	}
+}
+
+func (s *staticProvider) ForceIPV6()     {}
+func (s *staticProvider) String() string { return s.name }
+
+func TestGetIPQuorum(t *testing.T) {
+	t.Run("default quorum", func(t *testing.T) {
+		is := is.New(t)
+
+		ipp, err := New(Config{Timeout: 1 * time.Second, Strategy: "Quorum"})
+		is.NoErr(err)
+		is.Equal(ipp.(*IPProvider).quorum, 2)
+	})
+
+	tcases := []struct {
+		tname     string
+		quorum    int
+		providers []ipProvider
+		expected  string
+		isErr     bool
+	}{
+		{
+			tname:  "ok all agree",
+			quorum: 2,
+			providers: []ipProvider{
+				&staticProvider{name: "a", ip: "45.45.45.45"},
+				&staticProvider{name: "b", ip: "45.45.45.45"},
+				&staticProvider{name: "c", ip: "45.45.45.45"},
+			},
+			expected: "45.45.45.45",
+		},
+		{
+			tname:  "ok majority",
+			quorum: 2,
+			providers: []ipProvider{
+				&staticProvider{name: "a", ip: "45.45.45.45"},
+				&staticProvider{name: "b", ip: "66.66.66.66"},
+				&staticProvider{name: "c", ip: "45.45.45.45"},
+			},
+			expected: "45.45.45.45",
+		},
+		{
+			tname:  "ok with failure",
+			quorum: 2,
+			providers: []ipProvider{
+				&staticProvider{name: "a", err: errors.New("timeout")},
+				&staticProvider{name: "b", ip: "45.45.45.45"},
+				&staticProvider{name: "c", ip: "45.45.45.45"},
+			},
+			expected: "45.45.45.45",
+		},
+		{
+			tname:  "quorum not reached",
+			quorum: 3,
+			providers: []ipProvider{
+				&staticProvider{name: "a", ip: "45.45.45.45"},
+				&staticProvider{name: "b", ip: "66.66.66.66"},
+				&staticProvider{name: "c", ip: "45.45.45.45"},
+			},
+			isErr: true,
+		},
+		{
+			tname:  "split evenly",
+			quorum: 1,
+			providers: []ipProvider{
+				&staticProvider{name: "a", ip: "45.45.45.45"},
+				&staticProvider{name: "b", ip: "66.66.66.66"},
+			},
+			isErr: true,
+		},
+		{
+			tname:  "all failed",
+			quorum: 1,
+			providers: []ipProvider{
+				&staticProvider{name: "a", err: errors.New("timeout")},
+				&staticProvider{name: "b", err: errors.New("timeout")},
+			},
+			isErr: true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			ipp := &IPProvider{
+				providers: tc.providers,
+				strategy:  StrategyQuorum,
+				quorum:    tc.quorum,
+			}
+
+			ip, err := ipp.GetIP(context.Background())
+			if tc.isErr {
+				if err == nil {
+					t.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(ip, tc.expected)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tcases := []struct {
+		tname        string
+		ip           string
+		ipv6         bool
+		allowPrivate bool
+		expected     string
+		isErr        bool
+	}{
+		{tname: "ok ipv4", ip: " 45.45.45.45\n", expected: "45.45.45.45"},
+		{tname: "ok ipv6", ip: "2001:DB8:0:0:0:0:0:1", ipv6: true, expected: "2001:db8::1"},
+		{tname: "ok mapped ipv4", ip: "::ffff:45.45.45.45", expected: "45.45.45.45"},
+		{tname: "ok private allowed", ip: "192.168.1.5", allowPrivate: true, expected: "192.168.1.5"},
+		{tname: "ok cgnat allowed", ip: "100.64.1.1", allowPrivate: true, expected: "100.64.1.1"},
+		{tname: "html", ip: "<html><body>502 Bad Gateway</body></html>", isErr: true},
+		{tname: "empty", ip: "", isErr: true},
+		{tname: "ipv4 when ipv6 forced", ip: "45.45.45.45", ipv6: true, isErr: true},
+		{tname: "ipv6 when ipv4", ip: "2001:db8::1", isErr: true},
+		{tname: "private", ip: "10.0.0.1", isErr: true},
+		{tname: "loopback", ip: "127.0.0.1", isErr: true},
+		{tname: "link-local", ip: "169.254.1.1", isErr: true},
+		{tname: "link-local ipv6", ip: "fe80::1", ipv6: true, isErr: true},
+		{tname: "unique local ipv6", ip: "fd00::1", ipv6: true, isErr: true},
+		{tname: "cgnat", ip: "100.100.1.1", isErr: true},
+		{tname: "unspecified", ip: "0.0.0.0", allowPrivate: true, isErr: true},
+		{tname: "multicast", ip: "224.0.0.1", allowPrivate: true, isErr: true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			ipp := &IPProvider{ipv6: tc.ipv6, allowPrivate: tc.allowPrivate}
+			ip, err := ipp.validate(tc.ip)
+			if tc.isErr {
+				if err == nil {
+					t.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(ip, tc.expected)
+		})
+	}
+
+	t.Run("invalid responses are skipped", func(t *testing.T) {
+		is := is.New(t)
+
+		ipp := &IPProvider{
+			providers: []ipProvider{
+				&staticProvider{name: "a", ip: "<html>oops</html>"},
+				&staticProvider{name: "b", ip: "10.0.0.1"},
+				&staticProvider{name: "c", ip: "45.45.45.45"},
+			},
+			strategy: StrategySequential,
+		}
+
+		ip, err := ipp.GetIP(context.Background())
+		is.NoErr(err)
+		is.Equal(ip, "45.45.45.45")
+	})
+}
+
+func TestGetIPRace(t *testing.T) {
+	t.Run("default stagger", func(t *testing.T) {
+		is := is.New(t)
+
+		ipp, err := New(Config{Timeout: 1 * time.Second, Strategy: "race"})
+		is.NoErr(err)
+		is.Equal(ipp.(*IPProvider).stagger, defaultStagger)
+	})
+
+	t.Run("hanging provider is overtaken", func(t *testing.T) {
+		is := is.New(t)
+
+		hanging := &staticProvider{name: "a", ip: "66.66.66.66", delay: 10 * time.Second}
+		ipp := &IPProvider{
+			providers: []ipProvider{
+				hanging,
+				&staticProvider{name: "b", ip: "45.45.45.45"},
+			},
+			strategy: StrategyRace,
+			stagger:  50 * time.Millisecond,
+		}
+
+		started := time.Now()
+		ip, err := ipp.GetIP(context.Background())
+		is.NoErr(err)
+		is.Equal(ip, "45.45.45.45")
+		is.True(time.Since(started) < 1*time.Second)
+
+		// the hanging provider is canceled
+		time.Sleep(50 * time.Millisecond)
+		is.True(hanging.canceled.Load())
+	})
+
+	t.Run("fast first provider wins", func(t *testing.T) {
+		is := is.New(t)
+
+		second := &staticProvider{name: "b", ip: "66.66.66.66"}
+		ipp := &IPProvider{
+			providers: []ipProvider{
+				&staticProvider{name: "a", ip: "45.45.45.45"},
+				second,
+			},
+			strategy: StrategyRace,
+			stagger:  1 * time.Second,
+		}
+
+		ip, err := ipp.GetIP(context.Background())
+		is.NoErr(err)
+		is.Equal(ip, "45.45.45.45")
+	})
+
+	t.Run("failure starts next immediately", func(t *testing.T) {
+		is := is.New(t)
+
+		ipp := &IPProvider{
+			providers: []ipProvider{
+				&staticProvider{name: "a", err: errors.New("oops")},
+				&staticProvider{name: "b", ip: "<html></html>"},
+				&staticProvider{name: "c", ip: "45.45.45.45"},
+			},
+			strategy: StrategyRace,
+			stagger:  10 * time.Second,
+		}
+
+		started := time.Now()
+		ip, err := ipp.GetIP(context.Background())
+		is.NoErr(err)
+		is.Equal(ip, "45.45.45.45")
+		is.True(time.Since(started) < 1*time.Second)
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		ipp := &IPProvider{
+			providers: []ipProvider{
+				&staticProvider{name: "a", err: errors.New("oops")},
+				&staticProvider{name: "b", err: errors.New("oops")},
+			},
+			strategy: StrategyRace,
+			stagger:  10 * time.Millisecond,
+		}
+
+		if _, err := ipp.GetIP(context.Background()); err == nil {
+			t.Fail() // should be error
+		}
+	})
+}
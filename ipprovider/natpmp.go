@@ -0,0 +1,126 @@
+package ipprovider
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const natpmpPort = "5351"
+
+// natpmp asks the router for its external address using NAT-PMP (RFC 6886).
+type natpmp struct {
+	// Gateway is an address of the router, port defaults to 5351.
+	Gateway string
+
+	timeout time.Duration
+	ipv6    bool
+}
+
+func newNATPMP(cfg interface{}, timeout time.Duration) (*natpmp, error) {
+	var n natpmp
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if n.Gateway == "" {
+		return nil, errors.New("gateway can't be empty")
+	}
+
+	if _, _, err := net.SplitHostPort(n.Gateway); err != nil {
+		n.Gateway = net.JoinHostPort(n.Gateway, natpmpPort)
+	}
+
+	n.timeout = timeout
+
+	return &n, nil
+}
+
+// ForceIPV6 .
+func (n *natpmp) ForceIPV6() {
+	n.ipv6 = true
+}
+
+// String returns the name of the provider.
+func (n *natpmp) String() string {
+	return "natpmp"
+}
+
+// GetIP get IP
+func (n *natpmp) GetIP(ctx context.Context) (string, error) {
+	if n.ipv6 {
+		return "", errors.New("natpmp does not support ipv6")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp4", n.Gateway)
+	if err != nil {
+		return "", fmt.Errorf("failed to dial the gateway: %w", err)
+	}
+	defer conn.Close()
+
+	// external address request: version 0, opcode 0
+	resp, err := exchange(ctx, conn, []byte{0, 0}, 250*time.Millisecond)
+	if err != nil {
+		return "", err
+	}
+
+	return parseNATPMPResponse(resp)
+}
+
+// exchange sends the request to the gateway and returns the response.
+// The request is retransmitted starting with the wait interval,
+// doubling it every time, until the context expires.
+func exchange(ctx context.Context, conn net.Conn, req []byte, wait time.Duration) ([]byte, error) {
+	buf := make([]byte, 1100)
+	for ; ; wait *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, fmt.Errorf("failed to send a request: %w", err)
+		}
+
+		deadline := time.Now().Add(wait)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("failed to set deadline: %w", err)
+		}
+
+		size, err := conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read a response: %w", err)
+		}
+
+		return buf[:size], nil
+	}
+}
+
+// parseNATPMPResponse extracts external address from the response.
+func parseNATPMPResponse(b []byte) (string, error) {
+	if len(b) < 12 {
+		return "", fmt.Errorf("response is too short: %d bytes", len(b))
+	}
+
+	if b[0] != 0 || b[1] != 128 {
+		return "", fmt.Errorf("unexpected response version %d or opcode %d", b[0], b[1])
+	}
+
+	if code := binary.BigEndian.Uint16(b[2:4]); code != 0 {
+		return "", fmt.Errorf("gateway responded with result code %d", code)
+	}
+
+	return netip.AddrFrom4([4]byte(b[8:12])).String(), nil
+}
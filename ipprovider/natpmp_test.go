@@ -0,0 +1,98 @@
+package ipprovider
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// natpmpHelper answers NAT-PMP requests with the provided response.
+func natpmpHelper(t *testing.T, response []byte) (string, func()) {
+	is := is.New(t)
+	is.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	is.NoErr(err)
+
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if n != 2 || buf[0] != 0 || buf[1] != 0 {
+				continue
+			}
+
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestNATPMP(t *testing.T) {
+	t.Run("new ok", func(t *testing.T) {
+		is := is.New(t)
+
+		n, err := newNATPMP(map[string]interface{}{"gateway": "192.168.1.1"}, 10*time.Second)
+		is.NoErr(err)
+		is.Equal(n.Gateway, "192.168.1.1:5351")
+	})
+
+	t.Run("new no gateway", func(t *testing.T) {
+		if _, err := newNATPMP(map[string]interface{}{}, 10*time.Second); err == nil {
+			t.Fail() // should be error
+		}
+	})
+
+	t.Run("ipv6 fail", func(t *testing.T) {
+		is := is.New(t)
+
+		n, err := newNATPMP(map[string]interface{}{"gateway": "192.168.1.1"}, 10*time.Second)
+		is.NoErr(err)
+
+		n.ForceIPV6()
+		if _, err := n.GetIP(context.Background()); err == nil {
+			t.Fail() // should be error
+		}
+	})
+
+	tcases := []struct {
+		tname    string
+		response []byte
+		isErr    bool
+	}{
+		{"ok", []byte{0, 128, 0, 0, 0, 0, 0, 10, 45, 45, 45, 45}, false},
+		{"result code", []byte{0, 128, 0, 3, 0, 0, 0, 10, 0, 0, 0, 0}, true},
+		{"wrong opcode", []byte{0, 129, 0, 0, 0, 0, 0, 10, 45, 45, 45, 45}, true},
+		{"too short", []byte{0, 128, 0, 0}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			addr, close := natpmpHelper(t, tc.response)
+			defer close()
+
+			n, err := newNATPMP(map[string]interface{}{"gateway": addr}, 2*time.Second)
+			is.NoErr(err)
+
+			ip, err := n.GetIP(context.Background())
+			if tc.isErr {
+				if err == nil {
+					t.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(ip, "45.45.45.45")
+		})
+	}
+}
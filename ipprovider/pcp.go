@@ -0,0 +1,159 @@
+package ipprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// PCP constants, see RFC 6887.
+const (
+	pcpVersion  = 2
+	pcpOpMap    = 1
+	pcpResponse = 0x80
+	pcpProtoUDP = 17
+	// pcpLifetime is a lifetime of the mapping in seconds,
+	// the mapping is deleted right after the response anyway.
+	pcpLifetime = 30
+)
+
+// pcp asks the router for its external address using PCP (RFC 6887).
+// It requests a short-lived UDP mapping with MAP opcode, the response
+// contains the assigned external address, and deletes the mapping after.
+type pcp struct {
+	// Gateway is an address of the router, port defaults to 5351.
+	Gateway string
+
+	timeout time.Duration
+	ipv6    bool
+}
+
+func newPCP(cfg interface{}, timeout time.Duration) (*pcp, error) {
+	var p pcp
+	if err := mapstructure.Decode(cfg, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if p.Gateway == "" {
+		return nil, errors.New("gateway can't be empty")
+	}
+
+	if _, _, err := net.SplitHostPort(p.Gateway); err != nil {
+		p.Gateway = net.JoinHostPort(p.Gateway, natpmpPort)
+	}
+
+	p.timeout = timeout
+
+	return &p, nil
+}
+
+// ForceIPV6 .
+func (p *pcp) ForceIPV6() {
+	p.ipv6 = true
+}
+
+// String returns the name of the provider.
+func (p *pcp) String() string {
+	return "pcp"
+}
+
+// GetIP get IP
+func (p *pcp) GetIP(ctx context.Context) (string, error) {
+	if p.ipv6 {
+		return "", errors.New("pcp does not support ipv6")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp4", p.Gateway)
+	if err != nil {
+		return "", fmt.Errorf("failed to dial the gateway: %w", err)
+	}
+	defer conn.Close()
+
+	var nonce [12]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", fmt.Errorf("failed to generate a nonce: %w", err)
+	}
+
+	// the mapping is requested for the port of this socket,
+	// so nothing else is exposed by it
+	client := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+
+	resp, err := exchange(ctx, conn, pcpMapRequest(client, nonce, pcpLifetime), 250*time.Millisecond)
+	if err != nil {
+		return "", err
+	}
+
+	ip, err := parsePCPResponse(resp, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	// zero lifetime deletes the mapping, the response is not awaited
+	_, _ = conn.Write(pcpMapRequest(client, nonce, 0))
+
+	return ip, nil
+}
+
+// pcpMapRequest returns MAP request for the UDP port of the client.
+func pcpMapRequest(client netip.AddrPort, nonce [12]byte, lifetime uint32) []byte {
+	b := make([]byte, 60)
+
+	// common header
+	b[0] = pcpVersion
+	b[1] = pcpOpMap
+	binary.BigEndian.PutUint32(b[4:8], lifetime)
+	ip := client.Addr().As16()
+	copy(b[8:24], ip[:])
+
+	// MAP opcode, suggested external port is 0 and
+	// suggested external address is 0.0.0.0 (::ffff:0.0.0.0), so any is fine
+	copy(b[24:36], nonce[:])
+	b[36] = pcpProtoUDP
+	binary.BigEndian.PutUint16(b[40:42], client.Port())
+	b[54], b[55] = 0xff, 0xff
+
+	return b
+}
+
+// parsePCPResponse extracts the assigned external address from MAP response.
+func parsePCPResponse(b []byte, nonce [12]byte) (string, error) {
+	if len(b) >= 2 && b[0] == 0 {
+		// NAT-PMP gateways respond to unsupported versions with version 0
+		return "", errors.New("gateway supports only NAT-PMP")
+	}
+
+	if len(b) < 60 {
+		return "", fmt.Errorf("response is too short: %d bytes", len(b))
+	}
+
+	if b[0] != pcpVersion || b[1] != pcpResponse|pcpOpMap {
+		return "", fmt.Errorf("unexpected response version %d or opcode %d", b[0], b[1]&^pcpResponse)
+	}
+
+	if code := b[3]; code != 0 {
+		return "", fmt.Errorf("gateway responded with result code %d", code)
+	}
+
+	if !bytes.Equal(b[24:36], nonce[:]) {
+		return "", errors.New("response nonce does not match the request")
+	}
+
+	addr := netip.AddrFrom16([16]byte(b[44:60]))
+	if !addr.Is4In6() {
+		return "", fmt.Errorf("external address %s is not ipv4", addr)
+	}
+
+	return addr.Unmap().String(), nil
+}
@@ -0,0 +1,181 @@
+package ipprovider
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// pcpHelper answers PCP MAP requests with the response built from the request.
+func pcpHelper(t *testing.T, respond func(req []byte) []byte) (string, func()) {
+	is := is.New(t)
+	is.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	is.NoErr(err)
+
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if n != 60 || buf[0] != pcpVersion || buf[1] != pcpOpMap {
+				continue
+			}
+
+			_, _ = conn.WriteTo(respond(buf[:n]), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+// pcpMapResponse returns the successful response to the MAP request.
+func pcpMapResponse(req []byte, ip net.IP) []byte {
+	b := make([]byte, 60)
+	b[0] = pcpVersion
+	b[1] = pcpResponse | pcpOpMap
+	copy(b[4:8], req[4:8])
+	copy(b[24:44], req[24:44])
+	copy(b[44:60], ip.To16())
+
+	return b
+}
+
+func TestPCP(t *testing.T) {
+	t.Run("new ok", func(t *testing.T) {
+		is := is.New(t)
+
+		p, err := newPCP(map[string]interface{}{"gateway": "192.168.1.1"}, 10*time.Second)
+		is.NoErr(err)
+		is.Equal(p.Gateway, "192.168.1.1:5351")
+	})
+
+	t.Run("new no gateway", func(t *testing.T) {
+		if _, err := newPCP(map[string]interface{}{}, 10*time.Second); err == nil {
+			t.Fail() // should be error
+		}
+	})
+
+	t.Run("ipv6 fail", func(t *testing.T) {
+		is := is.New(t)
+
+		p, err := newPCP(map[string]interface{}{"gateway": "192.168.1.1"}, 10*time.Second)
+		is.NoErr(err)
+
+		p.ForceIPV6()
+		if _, err := p.GetIP(context.Background()); err == nil {
+			t.Fail() // should be error
+		}
+	})
+
+	tcases := []struct {
+		tname   string
+		respond func(req []byte) []byte
+		isErr   bool
+	}{
+		{
+			"ok",
+			func(req []byte) []byte {
+				return pcpMapResponse(req, net.ParseIP("45.45.45.45"))
+			},
+			false,
+		},
+		{
+			"result code",
+			func(req []byte) []byte {
+				b := pcpMapResponse(req, net.IPv4zero)
+				b[3] = 8 // NO_RESOURCES
+				return b
+			},
+			true,
+		},
+		{
+			"wrong nonce",
+			func(req []byte) []byte {
+				b := pcpMapResponse(req, net.ParseIP("45.45.45.45"))
+				b[24]++
+				return b
+			},
+			true,
+		},
+		{
+			"not ipv4",
+			func(req []byte) []byte {
+				return pcpMapResponse(req, net.ParseIP("2001:db8::1"))
+			},
+			true,
+		},
+		{
+			"natpmp only",
+			func(req []byte) []byte {
+				return []byte{0, 129, 0, 1, 0, 0, 0, 10}
+			},
+			true,
+		},
+		{
+			"too short",
+			func(req []byte) []byte {
+				return []byte{2, 129, 0, 0}
+			},
+			true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			addr, close := pcpHelper(t, tc.respond)
+			defer close()
+
+			p, err := newPCP(map[string]interface{}{"gateway": addr}, 2*time.Second)
+			is.NoErr(err)
+
+			ip, err := p.GetIP(context.Background())
+			if tc.isErr {
+				if err == nil {
+					t.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(ip, "45.45.45.45")
+		})
+	}
+
+	t.Run("request", func(t *testing.T) {
+		is := is.New(t)
+
+		requests := make(chan []byte, 2)
+		addr, close := pcpHelper(t, func(req []byte) []byte {
+			requests <- append([]byte(nil), req...)
+			return pcpMapResponse(req, net.ParseIP("45.45.45.45"))
+		})
+		defer close()
+
+		p, err := newPCP(map[string]interface{}{"gateway": addr}, 2*time.Second)
+		is.NoErr(err)
+
+		_, err = p.GetIP(context.Background())
+		is.NoErr(err)
+
+		// the mapping is requested for the client address and port
+		req := <-requests
+		is.Equal(req[4:8], []byte{0, 0, 0, pcpLifetime})
+		is.True(net.IP(req[8:24]).Equal(net.ParseIP("127.0.0.1")))
+		is.Equal(req[36], byte(pcpProtoUDP))
+		is.True(req[40] != 0 || req[41] != 0)
+
+		// and is deleted after
+		del := <-requests
+		is.Equal(del[4:8], []byte{0, 0, 0, 0})
+		is.Equal(del[24:44], req[24:44])
+	})
+}
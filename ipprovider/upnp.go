@@ -0,0 +1,293 @@
+package ipprovider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/skibish/ddns/misc"
+)
+
+const ssdpAddr = "239.255.255.250:1900"
+
+// searchTargets are the SSDP search targets of an Internet Gateway Device.
+var searchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+}
+
+// wanServices are the services which are able to report the external address.
+var wanServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:",
+	"urn:schemas-upnp-org:service:WANPPPConnection:",
+}
+
+// upnp asks the router for its external address
+// using the UPnP Internet Gateway Device protocol.
+type upnp struct {
+	// Location is an URL of the device description.
+	// If it is empty, the device is discovered with SSDP.
+	Location string
+
+	c        *http.Client
+	ssdpAddr string
+	timeout  time.Duration
+	ipv6     bool
+
+	mu sync.Mutex // guards controlURL and serviceType
+	// controlURL and serviceType of the WAN connection service are cached.
+	controlURL  string
+	serviceType string
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpResponse struct {
+	IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+}
+
+func newUPnP(cfg interface{}, timeout time.Duration) (*upnp, error) {
+	var u upnp
+	if err := mapstructure.Decode(cfg, &u); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	u.c = &http.Client{}
+	u.ssdpAddr = ssdpAddr
+	u.timeout = timeout
+
+	return &u, nil
+}
+
+// ForceIPV6 .
+func (u *upnp) ForceIPV6() {
+	u.ipv6 = true
+}
+
+// String returns the name of the provider.
+func (u *upnp) String() string {
+	return "upnp"
+}
+
+// GetIP get IP
+func (u *upnp) GetIP(ctx context.Context) (string, error) {
+	if u.ipv6 {
+		return "", errors.New("upnp does not support ipv6")
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	u.mu.Lock()
+	controlURL, serviceType := u.controlURL, u.serviceType
+	u.mu.Unlock()
+
+	if controlURL == "" {
+		var err error
+		controlURL, serviceType, err = u.lookupService(reqCtx)
+		if err != nil {
+			return "", err
+		}
+
+		u.mu.Lock()
+		u.controlURL, u.serviceType = controlURL, serviceType
+		u.mu.Unlock()
+	}
+
+	ip, err := u.externalIP(reqCtx, controlURL, serviceType)
+	if err != nil {
+		// the router could have been restarted with a different
+		// control URL, so discover it once again next time,
+		// unless the request has been canceled (e.g. another provider was faster)
+		if ctx.Err() == nil {
+			u.mu.Lock()
+			if u.controlURL == controlURL {
+				u.controlURL, u.serviceType = "", ""
+			}
+			u.mu.Unlock()
+		}
+		return "", err
+	}
+
+	return ip, nil
+}
+
+// lookupService finds the control URL and the type of the WAN connection service.
+func (u *upnp) lookupService(ctx context.Context) (string, string, error) {
+	location := u.Location
+	if location == "" {
+		var err error
+		location, err = u.discover(ctx)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to discover a gateway: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create a request: %w", err)
+	}
+
+	resp, err := u.c.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to do a request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if !misc.Success(resp.StatusCode) {
+		return "", "", fmt.Errorf("status code is not in success range: %d", resp.StatusCode)
+	}
+
+	var d upnpDescription
+	if err := xml.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return "", "", fmt.Errorf("failed to decode the device description: %w", err)
+	}
+
+	s, ok := findWANService(d.Device)
+	if !ok {
+		return "", "", errors.New("gateway has no wan connection service")
+	}
+
+	base := location
+	if d.URLBase != "" {
+		base = d.URLBase
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse the base url: %w", err)
+	}
+
+	controlURL, err := baseURL.Parse(s.ControlURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse the control url: %w", err)
+	}
+
+	return controlURL.String(), s.ServiceType, nil
+}
+
+// discover sends an SSDP search request and returns
+// the location of the first gateway which responded.
+func (u *upnp) discover(ctx context.Context) (string, error) {
+	addr, err := net.ResolveUDPAddr("udp4", u.ssdpAddr)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ssdp address: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return "", fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+
+	for _, st := range searchTargets {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddr + "\r\n" +
+			"ST: " + st + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(msg), addr); err != nil {
+			return "", fmt.Errorf("failed to send a search request: %w", err)
+		}
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", fmt.Errorf("failed to read a search response: %w", err)
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// externalIP calls GetExternalIPAddress action of the WAN connection service.
+func (u *upnp) externalIP(ctx context.Context, controlURL, serviceType string) (string, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"></u:GetExternalIPAddress></s:Body>` +
+		`</s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create a request: %w", err)
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#GetExternalIPAddress"`, serviceType))
+
+	resp, err := u.c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to do a request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if !misc.Success(resp.StatusCode) {
+		return "", fmt.Errorf("status code is not in success range: %d", resp.StatusCode)
+	}
+
+	var r upnpResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("failed to decode the response: %w", err)
+	}
+
+	return strings.TrimSpace(r.IP), nil
+}
+
+// findWANService searches the device tree for the WAN connection service.
+func findWANService(d upnpDevice) (upnpService, bool) {
+	for _, s := range d.Services {
+		for _, prefix := range wanServices {
+			if strings.HasPrefix(s.ServiceType, prefix) {
+				return s, true
+			}
+		}
+	}
+
+	for _, child := range d.Devices {
+		if s, ok := findWANService(child); ok {
+			return s, true
+		}
+	}
+
+	return upnpService{}, false
+}
@@ -0,0 +1,206 @@
+package ipprovider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+const upnpDescriptionXML = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const upnpSOAPResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+      <NewExternalIPAddress>45.45.45.45</NewExternalIPAddress>
+    </u:GetExternalIPAddressResponse>
+  </s:Body>
+</s:Envelope>`
+
+// gatewayHelper starts the device description and SOAP control endpoints.
+func gatewayHelper(t *testing.T, controlStatus int) (string, func()) {
+	is := is.New(t)
+	is.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(upnpDescriptionXML))
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Method, http.MethodPost)
+		is.Equal(r.Header.Get("SOAPAction"), `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"`)
+
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.True(strings.Contains(string(b), "GetExternalIPAddress"))
+
+		w.WriteHeader(controlStatus)
+		_, _ = w.Write([]byte(upnpSOAPResponse))
+	})
+
+	server := httptest.NewServer(mux)
+
+	return server.URL + "/rootDesc.xml", server.Close
+}
+
+// ssdpHelper answers SSDP search requests with the provided location.
+func ssdpHelper(t *testing.T, location string) (string, func()) {
+	is := is.New(t)
+	is.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	is.NoErr(err)
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if !strings.HasPrefix(string(buf[:n]), "M-SEARCH") {
+				continue
+			}
+
+			resp := fmt.Sprintf("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: %s\r\nLOCATION: %s\r\n\r\n", searchTargets[0], location)
+			_, _ = conn.WriteTo([]byte(resp), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestUPnP(t *testing.T) {
+	t.Run("new ok", func(t *testing.T) {
+		is := is.New(t)
+
+		u, err := newUPnP(map[string]interface{}{"location": "http://192.168.1.1/rootDesc.xml"}, 10*time.Second)
+		is.NoErr(err)
+		is.Equal(u.Location, "http://192.168.1.1/rootDesc.xml")
+		is.Equal(u.ssdpAddr, ssdpAddr)
+	})
+
+	t.Run("ipv6 fail", func(t *testing.T) {
+		u, err := newUPnP(map[string]interface{}{}, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u.ForceIPV6()
+		if _, err := u.GetIP(context.Background()); err == nil {
+			t.Fail() // should be error
+		}
+	})
+
+	tcases := []struct {
+		tname         string
+		discover      bool
+		controlStatus int
+		isErr         bool
+	}{
+		{"ok location", false, http.StatusOK, false},
+		{"ok discover", true, http.StatusOK, false},
+		{"not ok", false, http.StatusInternalServerError, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			location, close := gatewayHelper(t, tc.controlStatus)
+			defer close()
+
+			addr, closeSSDP := ssdpHelper(t, location)
+			defer closeSSDP()
+
+			u, err := newUPnP(map[string]interface{}{}, 2*time.Second)
+			is.NoErr(err)
+			u.ssdpAddr = addr
+
+			if !tc.discover {
+				u.Location = location
+			}
+
+			ip, err := u.GetIP(context.Background())
+			if tc.isErr {
+				if err == nil {
+					t.Fail() // should be error
+				}
+				is.Equal(u.controlURL, "")
+				return
+			}
+			is.NoErr(err)
+			is.Equal(ip, "45.45.45.45")
+			is.True(strings.HasSuffix(u.controlURL, "/ctl/IPConn"))
+		})
+	}
+
+	t.Run("canceled keeps control url", func(t *testing.T) {
+		is := is.New(t)
+
+		location, close := gatewayHelper(t, http.StatusOK)
+		defer close()
+
+		u, err := newUPnP(map[string]interface{}{"location": location}, 2*time.Second)
+		is.NoErr(err)
+
+		_, err = u.GetIP(context.Background())
+		is.NoErr(err)
+
+		// another provider has won the race
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = u.GetIP(ctx)
+		is.True(err != nil)
+		is.True(strings.HasSuffix(u.controlURL, "/ctl/IPConn"))
+	})
+
+	t.Run("no gateway", func(t *testing.T) {
+		u, err := newUPnP(map[string]interface{}{}, 300*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// nobody listens there
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.ssdpAddr = conn.LocalAddr().String()
+		defer conn.Close()
+
+		if _, err := u.GetIP(context.Background()); err == nil {
+			t.Fail() // should be error
+		}
+	})
+}
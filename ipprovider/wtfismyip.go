@@ -36,6 +36,11 @@ func (i *wtfIsMyIP) ForceIPV6() {
 	i.url = "https://ipv6.wtfismyip.com/json"
 }
 
+// String returns the name of the provider.
+func (i *wtfIsMyIP) String() string {
+	return "wtfismyip"
+}
+
 // GetIP get IP
 func (i *wtfIsMyIP) GetIP(ctx context.Context) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, i.timeout)
@@ -13,7 +13,6 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skibish/ddns/conf"
-	"github.com/skibish/ddns/notifier"
 )
 
 var (
@@ -56,26 +55,18 @@ func run(args []string, stdout io.Writer) error {
 		log.Fatal(err)
 	}
 
-	// try to register all provided hooks
-	for _, v := range cf.Notifications {
-		hook, err := notifier.GetHook(v)
-		if err != nil {
-			log.Debugf("failed to add a notifier: %s", err)
-			continue
-		}
-		log.AddHook(hook)
+	upd, err := updater.New(cf)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	upd := updater.New(cf)
-
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	go func() {
 		<-ctx.Done()
 		log.Debug("shutdown")
 		upd.Stop()
-		stop()
-		os.Exit(0)
 	}()
 
 	return upd.Start(ctx)
@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// discoveryDevice groups the entities of ddns in Home Assistant.
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// discoveryConfig is a configuration of the Home Assistant entity,
+// see https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery.
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	Icon                string          `json:"icon,omitempty"`
+	DeviceClass         string          `json:"device_class,omitempty"`
+	StateTopic          string          `json:"state_topic"`
+	ValueTemplate       string          `json:"value_template,omitempty"`
+	JSONAttributesTopic string          `json:"json_attributes_topic,omitempty"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	Device              discoveryDevice `json:"device"`
+}
+
+// entity returns the configuration of the entity with common fields set.
+func (c *Client) entity(id, name, topic string) discoveryConfig {
+	return discoveryConfig{
+		Name:              name,
+		UniqueID:          c.node + "_" + id,
+		StateTopic:        c.cfg.Topic + "/" + topic,
+		AvailabilityTopic: c.cfg.Topic + "/status",
+		Device: discoveryDevice{
+			Identifiers:  []string{c.node},
+			Name:         c.cfg.ClientID,
+			Manufacturer: "ddns",
+			Model:        "DigitalOcean dynamic DNS",
+		},
+	}
+}
+
+// ipDiscovery returns the sensor with the current IP.
+func (c *Client) ipDiscovery() discoveryConfig {
+	e := c.entity("ip", "IP", "ip")
+	e.Icon = "mdi:ip-network"
+	return e
+}
+
+// syncDiscovery returns the binary sensor, which is on, if the last sync failed.
+func (c *Client) syncDiscovery() discoveryConfig {
+	e := c.entity("sync", "Sync", "sync")
+	e.DeviceClass = "problem"
+	e.ValueTemplate = fmt.Sprintf("{{ 'ON' if value_json.status == '%s' else 'OFF' }}", StatusFailed)
+	e.JSONAttributesTopic = e.StateTopic
+	return e
+}
+
+// recordDiscovery returns the sensor with the data of the record.
+func (c *Client) recordDiscovery(r RecordStatus) discoveryConfig {
+	topic := recordTopic(r)
+	e := c.entity(sanitize(topic), fmt.Sprintf("%s %s %s", r.Type, r.Name, r.Domain), topic)
+	e.Icon = "mdi:dns"
+	e.ValueTemplate = "{{ value_json.data }}"
+	e.JSONAttributesTopic = e.StateTopic
+	return e
+}
+
+// discoveryMessage returns the retained message,
+// which announces the entity of the component to Home Assistant.
+func (c *Client) discoveryMessage(component string, cfg discoveryConfig) (message, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return message{}, fmt.Errorf("failed to encode the discovery config: %w", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s/%s/%s/config", c.cfg.DiscoveryPrefix, component, c.node, sanitize(cfg.UniqueID))
+	return message{Topic: topic, Payload: b, QoS: byte(c.cfg.QoS), Retain: true}, nil
+}
@@ -0,0 +1,407 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults of the configuration.
+const (
+	defaultTopic           = "ddns"
+	defaultDiscoveryPrefix = "homeassistant"
+	defaultKeepAlive       = time.Minute
+	// disconnectQuiesce is for how long pending work is completed before disconnect.
+	disconnectQuiesce = 250 * time.Millisecond
+)
+
+// schemes are the supported schemes of the broker url.
+var schemes = map[string]bool{
+	"tcp":   true,
+	"mqtt":  true,
+	"ssl":   true,
+	"tls":   true,
+	"mqtts": true,
+}
+
+// Payloads of the availability topic.
+const (
+	online  = "online"
+	offline = "offline"
+)
+
+// Statuses of the sync.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// unsafe matches characters, which are not allowed in topic levels and ids.
+var unsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Publisher publishes the state of ddns.
+type Publisher interface {
+	// PublishIP publishes the current IP.
+	PublishIP(ctx context.Context, ip string) error
+	// PublishSync publishes the result of the sync and statuses of the records.
+	PublishSync(ctx context.Context, s SyncStatus) error
+	// Close marks ddns as offline and disconnects.
+	Close(ctx context.Context) error
+}
+
+// SyncStatus is a result of the sync.
+type SyncStatus struct {
+	IP       string
+	Time     time.Time
+	Duration time.Duration
+	Records  []RecordStatus
+	// Error is empty, if all records have been synced.
+	Error string
+}
+
+// RecordStatus is a result of the sync of a single record.
+type RecordStatus struct {
+	Domain string
+	Type   string
+	Name   string
+	Data   string
+	Action string
+	Error  string
+}
+
+// Config is a configuration of the MQTT publisher.
+type Config struct {
+	// URL of the broker, e.g. tcp://localhost:1883 or ssl://localhost:8883.
+	URL      string
+	Username string
+	Password string
+	// ClientID is ddns-<hostname> by default.
+	ClientID string `mapstructure:"client_id"`
+	// Topic is a prefix of the topics.
+	Topic string
+	// QoS is 0 or 1.
+	QoS       int
+	KeepAlive time.Duration `mapstructure:"keep_alive"`
+
+	// Discovery enables Home Assistant MQTT discovery.
+	Discovery       bool
+	DiscoveryPrefix string `mapstructure:"discovery_prefix"`
+}
+
+// Client publishes the state of ddns to the MQTT broker as retained messages.
+// It connects on the first publish and reconnects automatically after failures.
+type Client struct {
+	cfg     Config
+	timeout time.Duration
+	// node identifies ddns in Home Assistant.
+	node   string
+	client paho.Client
+
+	mu sync.Mutex
+	// announced are the unique ids of the entities,
+	// which have been announced to Home Assistant.
+	announced map[string]bool
+}
+
+// message is a message to publish.
+type message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// New returns the MQTT publisher for the configuration.
+// Timeout limits connecting and writing to the broker.
+func New(cfg interface{}, timeout time.Duration) (*Client, error) {
+	var c Config
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &c,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a decoder: %w", err)
+	}
+
+	if err := d.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	if !schemes[u.Scheme] {
+		return nil, fmt.Errorf("scheme %s is not supported", u.Scheme)
+	}
+
+	if c.QoS < 0 || c.QoS > 1 {
+		return nil, fmt.Errorf("qos %d is not supported, it should be 0 or 1", c.QoS)
+	}
+
+	if c.ClientID == "" {
+		name, _ := os.Hostname()
+		c.ClientID = "ddns-" + name
+	}
+
+	c.Topic = strings.Trim(c.Topic, "/")
+	if c.Topic == "" {
+		c.Topic = defaultTopic
+	}
+
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = defaultKeepAlive
+	}
+
+	c.DiscoveryPrefix = strings.Trim(c.DiscoveryPrefix, "/")
+	if c.DiscoveryPrefix == "" {
+		c.DiscoveryPrefix = defaultDiscoveryPrefix
+	}
+
+	client := &Client{
+		cfg:       c,
+		timeout:   timeout,
+		node:      sanitize(c.ClientID),
+		announced: make(map[string]bool),
+	}
+
+	// the broker marks ddns as offline, if it disconnects unexpectedly
+	will := client.message("status", []byte(offline))
+	opts := paho.NewClientOptions().
+		AddBroker(c.URL).
+		SetClientID(c.ClientID).
+		SetUsername(c.Username).
+		SetPassword(c.Password).
+		SetKeepAlive(c.KeepAlive).
+		SetWill(will.Topic, string(will.Payload), will.QoS, will.Retain).
+		SetConnectTimeout(timeout).
+		SetWriteTimeout(timeout).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(client.connected)
+	client.client = paho.NewClient(opts)
+
+	return client, nil
+}
+
+// PublishIP publishes the current IP to <topic>/ip.
+func (c *Client) PublishIP(ctx context.Context, ip string) error {
+	return c.publish(ctx, []message{c.message("ip", []byte(ip))})
+}
+
+// syncPayload is published to <topic>/sync.
+type syncPayload struct {
+	Status string    `json:"status"`
+	IP     string    `json:"ip"`
+	Time   time.Time `json:"time"`
+	// Duration is in seconds.
+	Duration float64        `json:"duration"`
+	Actions  map[string]int `json:"actions"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// recordPayload is published to <topic>/records/<domain>/<type>/<name>.
+type recordPayload struct {
+	Domain string    `json:"domain"`
+	Type   string    `json:"type"`
+	Name   string    `json:"name"`
+	Data   string    `json:"data"`
+	Action string    `json:"action"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// PublishSync publishes the result of the sync to <topic>/sync
+// and the status of every record to <topic>/records/<domain>/<type>/<name>.
+func (c *Client) PublishSync(ctx context.Context, s SyncStatus) error {
+	p := syncPayload{
+		Status:   StatusOK,
+		IP:       s.IP,
+		Time:     s.Time,
+		Duration: s.Duration.Seconds(),
+		Actions:  make(map[string]int),
+		Error:    s.Error,
+	}
+	if s.Error != "" {
+		p.Status = StatusFailed
+	}
+
+	msgs := make([]message, 0, len(s.Records)+1)
+	for _, r := range s.Records {
+		p.Actions[r.Action]++
+
+		b, err := json.Marshal(recordPayload{Domain: r.Domain, Type: r.Type, Name: r.Name, Data: r.Data, Action: r.Action, Error: r.Error, Time: s.Time})
+		if err != nil {
+			return fmt.Errorf("failed to encode the status of the record: %w", err)
+		}
+		msgs = append(msgs, c.message(recordTopic(r), b))
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode the status of the sync: %w", err)
+	}
+	msgs = append(msgs, c.message("sync", b))
+
+	return c.publish(ctx, msgs, s.Records...)
+}
+
+// Close publishes offline to <topic>/status and disconnects.
+func (c *Client) Close(ctx context.Context) error {
+	if !c.client.IsConnected() {
+		return nil
+	}
+
+	var err error
+	if c.client.IsConnectionOpen() {
+		err = c.send(ctx, []message{c.message("status", []byte(offline))})
+	}
+
+	// pending work is given a moment to complete, but not longer than ctx allows
+	quiesce := disconnectQuiesce
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < quiesce {
+		quiesce = max(time.Until(deadline), 0)
+	}
+	c.client.Disconnect(uint(quiesce.Milliseconds()))
+
+	return err
+}
+
+// publish publishes the messages, it connects to the broker, if needed,
+// and announces the records to Home Assistant, if they are new.
+func (c *Client) publish(ctx context.Context, msgs []message, records ...RecordStatus) error {
+	if err := c.connect(ctx); err != nil {
+		return err
+	}
+
+	if c.cfg.Discovery {
+		c.mu.Lock()
+		for _, r := range records {
+			cfg := c.recordDiscovery(r)
+			if c.announced[cfg.UniqueID] {
+				continue
+			}
+
+			m, err := c.discoveryMessage("sensor", cfg)
+			if err != nil {
+				c.mu.Unlock()
+				return err
+			}
+			msgs = append([]message{m}, msgs...)
+		}
+		c.mu.Unlock()
+	}
+
+	if err := c.send(ctx, msgs); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	for _, r := range records {
+		c.announced[c.recordDiscovery(r).UniqueID] = true
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// connect connects to the broker, unless the client is connected or reconnecting.
+func (c *Client) connect(ctx context.Context) error {
+	if c.client.IsConnected() {
+		return nil
+	}
+
+	if err := wait(ctx, c.client.Connect()); err != nil {
+		return fmt.Errorf("failed to connect to the broker: %w", err)
+	}
+
+	return nil
+}
+
+// connected publishes online to <topic>/status and announces ddns to Home Assistant
+// every time the connection is established.
+func (c *Client) connected(paho.Client) {
+	// retained discovery messages could have been lost with the broker,
+	// so the entities are announced again on every connect
+	c.mu.Lock()
+	c.announced = make(map[string]bool)
+	c.mu.Unlock()
+
+	msgs := []message{c.message("status", []byte(online))}
+	if c.cfg.Discovery {
+		for _, d := range []struct {
+			component string
+			cfg       discoveryConfig
+		}{
+			{"sensor", c.ipDiscovery()},
+			{"binary_sensor", c.syncDiscovery()},
+		} {
+			m, err := c.discoveryMessage(d.component, d.cfg)
+			if err != nil {
+				log.Warnf("mqtt: %s", err)
+				return
+			}
+			msgs = append(msgs, m)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	if err := c.send(ctx, msgs); err != nil {
+		log.Warnf("mqtt: failed to publish the status: %s", err)
+	}
+}
+
+// send publishes the messages and waits until they are delivered
+// (acknowledged by the broker with QoS 1) or ctx is done.
+func (c *Client) send(ctx context.Context, msgs []message) error {
+	for _, m := range msgs {
+		if err := wait(ctx, c.client.Publish(m.Topic, m.QoS, m.Retain, m.Payload)); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", m.Topic, err)
+		}
+	}
+
+	return nil
+}
+
+// wait waits until the token completes or ctx is done.
+func wait(ctx context.Context, t paho.Token) error {
+	select {
+	case <-t.Done():
+		return t.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message returns a retained message to the topic under the prefix.
+func (c *Client) message(topic string, payload []byte) message {
+	return message{Topic: c.cfg.Topic + "/" + topic, Payload: payload, QoS: byte(c.cfg.QoS), Retain: true}
+}
+
+// recordTopic returns the topic of the record relative to the prefix.
+func recordTopic(r RecordStatus) string {
+	return "records/" + topicLevel(r.Domain) + "/" + topicLevel(r.Type) + "/" + topicLevel(r.Name)
+}
+
+// topicLevel replaces wildcards and separators, which are not allowed in a topic level.
+func topicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
+
+// sanitize returns s, which is safe to use in ids and discovery topics.
+func sanitize(s string) string {
+	return strings.Trim(unsafe.ReplaceAllString(s, "_"), "_")
+}
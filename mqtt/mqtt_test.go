@@ -0,0 +1,350 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/matryer/is"
+)
+
+// broker is a fake MQTT broker, which keeps retained messages.
+type broker struct {
+	ln       net.Listener
+	password string
+	done     chan struct{}
+
+	mu sync.Mutex
+	// stalled broker accepts connections, but doesn't read from them.
+	stalled  bool
+	retained map[string]string
+	// history are all payloads stored per topic.
+	history map[string][]string
+	clients []string
+	conns   []net.Conn
+}
+
+func newBroker(t *testing.T, password string) *broker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &broker{ln: ln, password: password, done: make(chan struct{}), retained: make(map[string]string), history: make(map[string][]string)}
+	go b.serve()
+	t.Cleanup(func() { b.close() })
+
+	return b
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *broker) serve() {
+	for {
+		nc, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		b.mu.Lock()
+		b.conns = append(b.conns, nc)
+		b.mu.Unlock()
+
+		go b.handle(nc)
+	}
+}
+
+func (b *broker) handle(nc net.Conn) {
+	defer nc.Close()
+
+	p, err := packets.ReadPacket(nc)
+	if err != nil {
+		return
+	}
+	connect, ok := p.(*packets.ConnectPacket)
+	if !ok {
+		return
+	}
+
+	ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	if string(connect.Password) != b.password {
+		ack.ReturnCode = packets.ErrRefusedBadUsernameOrPassword
+	}
+	if err := ack.Write(nc); err != nil || ack.ReturnCode != packets.Accepted {
+		return
+	}
+
+	b.mu.Lock()
+	b.clients = append(b.clients, connect.ClientIdentifier)
+	stalled := b.stalled
+	b.mu.Unlock()
+
+	if stalled {
+		// the connection stays open, but nothing is read or acknowledged
+		<-b.done
+		return
+	}
+
+	for {
+		p, err := packets.ReadPacket(nc)
+		if err != nil {
+			// connection is lost, so the will is published
+			if connect.WillFlag && connect.WillRetain {
+				b.store(connect.WillTopic, connect.WillMessage)
+			}
+			return
+		}
+
+		switch p := p.(type) {
+		case *packets.PublishPacket:
+			if p.Retain {
+				b.store(p.TopicName, p.Payload)
+			}
+			if p.Qos > 0 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				_ = ack.Write(nc)
+			}
+		case *packets.PingreqPacket:
+			_ = packets.NewControlPacket(packets.Pingresp).Write(nc)
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func (b *broker) store(topic string, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.retained[topic] = string(payload)
+	b.history[topic] = append(b.history[topic], string(payload))
+}
+
+func (b *broker) get(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	v, ok := b.retained[topic]
+	return v, ok
+}
+
+// drop closes all client connections.
+func (b *broker) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, nc := range b.conns {
+		nc.Close()
+	}
+	b.conns = nil
+}
+
+func (b *broker) close() {
+	close(b.done)
+	b.ln.Close()
+	b.drop()
+}
+
+// eventually waits until f returns true.
+func eventually(t *testing.T, f func() bool) {
+	t.Helper()
+
+	for i := 0; i < 500; i++ {
+		if f() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition is not met")
+}
+
+func TestNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]interface{}{"url": "tcp://localhost:1883"}, false},
+		{"ok discovery", map[string]interface{}{"url": "ssl://localhost", "discovery": true, "keep_alive": "30s"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid url", map[string]interface{}{"url": "oh no"}, true},
+		{"unsupported scheme", map[string]interface{}{"url": "http://localhost:1883"}, true},
+		{"invalid qos", map[string]interface{}{"url": "tcp://localhost:1883", "qos": 2}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := New(tc.cfg, time.Second)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestClientPublish(t *testing.T) {
+	tcases := []struct {
+		tname     string
+		qos       int
+		discovery bool
+	}{
+		{"qos 0", 0, false},
+		{"qos 1 with discovery", 1, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			b := newBroker(t, "secret")
+			c, err := New(map[string]interface{}{
+				"url":       b.url(),
+				"username":  "ddns",
+				"password":  "secret",
+				"client_id": "ddns-router",
+				"topic":     "home/ddns/",
+				"qos":       tc.qos,
+				"discovery": tc.discovery,
+			}, time.Second)
+			is.NoErr(err)
+
+			ctx := context.Background()
+			is.NoErr(c.PublishIP(ctx, "10.0.0.1"))
+			is.NoErr(c.PublishSync(ctx, SyncStatus{
+				IP:       "10.0.0.1",
+				Time:     time.Unix(1700000000, 0).UTC(),
+				Duration: 1500 * time.Millisecond,
+				Records: []RecordStatus{
+					{Domain: "example.com", Type: "A", Name: "@", Data: "10.0.0.1", Action: "updated"},
+					{Domain: "example.com", Type: "A", Name: "www", Data: "10.0.0.1", Action: "failed", Error: "api is down"},
+				},
+				Error: "api is down",
+			}))
+
+			eventually(t, func() bool {
+				_, ok := b.get("home/ddns/sync")
+				return ok
+			})
+
+			ip, _ := b.get("home/ddns/ip")
+			is.Equal(ip, "10.0.0.1")
+
+			status, _ := b.get("home/ddns/status")
+			is.Equal(status, "online")
+
+			raw, _ := b.get("home/ddns/sync")
+			var s syncPayload
+			is.NoErr(json.Unmarshal([]byte(raw), &s))
+			is.Equal(s.Status, StatusFailed)
+			is.Equal(s.Duration, 1.5)
+			is.Equal(s.Actions, map[string]int{"updated": 1, "failed": 1})
+
+			raw, _ = b.get("home/ddns/records/example.com/A/www")
+			var r recordPayload
+			is.NoErr(json.Unmarshal([]byte(raw), &r))
+			is.Equal(r.Action, "failed")
+			is.Equal(r.Error, "api is down")
+
+			_, ok := b.get("homeassistant/sensor/ddns-router/ddns-router_ip/config")
+			is.Equal(ok, tc.discovery)
+
+			raw, ok = b.get("homeassistant/sensor/ddns-router/ddns-router_records_example_com_A_www/config")
+			is.Equal(ok, tc.discovery)
+			if tc.discovery {
+				var d discoveryConfig
+				is.NoErr(json.Unmarshal([]byte(raw), &d))
+				is.Equal(d.StateTopic, "home/ddns/records/example.com/A/www")
+				is.Equal(d.AvailabilityTopic, "home/ddns/status")
+
+				_, ok = b.get("homeassistant/binary_sensor/ddns-router/ddns-router_sync/config")
+				is.True(ok)
+			}
+
+			is.NoErr(c.Close(ctx))
+			eventually(t, func() bool {
+				status, _ := b.get("home/ddns/status")
+				return status == "offline"
+			})
+		})
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	is := is.New(t)
+
+	b := newBroker(t, "")
+	c, err := New(map[string]interface{}{"url": b.url(), "qos": 1}, time.Second)
+	is.NoErr(err)
+
+	ctx := context.Background()
+	is.NoErr(c.PublishIP(ctx, "10.0.0.1"))
+
+	b.drop()
+
+	// the broker publishes the will and the client marks ddns as online again after reconnect
+	eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return reflect.DeepEqual(b.history["ddns/status"], []string{"online", "offline", "online"})
+	})
+
+	is.NoErr(c.PublishIP(ctx, "10.0.0.2"))
+
+	ip, _ := b.get("ddns/ip")
+	is.Equal(ip, "10.0.0.2")
+
+	b.mu.Lock()
+	is.Equal(len(b.clients), 2)
+	b.mu.Unlock()
+}
+
+func TestClientRefused(t *testing.T) {
+	is := is.New(t)
+
+	b := newBroker(t, "secret")
+	c, err := New(map[string]interface{}{"url": b.url(), "password": "wrong"}, time.Second)
+	is.NoErr(err)
+
+	err = c.PublishIP(context.Background(), "10.0.0.1")
+	is.True(errors.Is(err, packets.ErrorRefusedBadUsernameOrPassword))
+}
+
+func TestClientStalled(t *testing.T) {
+	is := is.New(t)
+
+	b := newBroker(t, "")
+	b.stalled = true
+
+	c, err := New(map[string]interface{}{"url": b.url(), "qos": 1}, time.Second)
+	is.NoErr(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = c.PublishIP(ctx, "10.0.0.1")
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	is.True(c.Close(ctx) != nil)             // offline is not acknowledged
+	is.True(time.Since(start) < time.Second) // publishing and closing are limited by ctx
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rtnetlink multicast groups, see rtnetlink(7).
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+)
+
+// netlink watches address and route changes using rtnetlink.
+type netlink struct{}
+
+// New return Watcher for the current platform.
+func New() Watcher {
+	return &netlink{}
+}
+
+// Watch subscribes to RTM_NEWADDR, RTM_DELADDR, RTM_NEWROUTE and RTM_DELROUTE events.
+func (n *netlink) Watch(ctx context.Context) (<-chan struct{}, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr | rtmgrpIPv4Route | rtmgrpIPv6Route,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind netlink socket: %w", err)
+	}
+
+	// non-blocking descriptor is registered in the runtime poller,
+	// so that closing the file interrupts a pending read
+	f := os.NewFile(uintptr(fd), "netlink")
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+
+		buf := make([]byte, os.Getpagesize()*4)
+		for {
+			size, err := f.Read(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				if errors.Is(err, syscall.EINTR) {
+					continue
+				}
+
+				if errors.Is(err, syscall.ENOBUFS) {
+					// the socket buffer has overflown during a burst of changes,
+					// so the changes are lost, but something has changed anyway
+					log.Warnf("some network changes are missed: %s", err)
+					notify(ch)
+					continue
+				}
+
+				log.Warnf("failed to read netlink messages: %s", err)
+				return
+			}
+
+			msgs, err := syscall.ParseNetlinkMessage(buf[:size])
+			if err != nil {
+				log.Debugf("failed to parse netlink messages: %s", err)
+				continue
+			}
+
+			for _, m := range msgs {
+				switch m.Header.Type {
+				case syscall.RTM_NEWADDR, syscall.RTM_DELADDR, syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+					notify(ch)
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
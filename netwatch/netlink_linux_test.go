@@ -0,0 +1,33 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNetlinkWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := New().Watch(ctx)
+	if err != nil {
+		t.Skipf("netlink is not available: %s", err)
+	}
+
+	cancel()
+
+	// the channel is closed after the context is canceled
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel is not closed after cancel")
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package netwatch
+
+import "context"
+
+// Watcher notifies about network changes
+// after which the external IP could have changed.
+type Watcher interface {
+	// Watch return a channel which receives a value on every change.
+	// The channel is closed, when watching stops.
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// notify sends to ch without blocking,
+// changes which are not consumed yet are merged.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+)
+
+// unsupported is a Watcher for platforms without change notifications.
+type unsupported struct{}
+
+// New return Watcher for the current platform.
+func New() Watcher {
+	return &unsupported{}
+}
+
+// Watch always fails.
+func (u *unsupported) Watch(context.Context) (<-chan struct{}, error) {
+	return nil, errors.New("watching network changes is not supported on this platform")
+}
@@ -0,0 +1,176 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/skibish/ddns/misc"
+)
+
+// Limits of the Discord messages.
+const (
+	discordMaxRetries = 3
+	// discordMaxChanges is how many changed records are listed,
+	// every record takes 3 of 25 allowed fields.
+	discordMaxChanges = 7
+)
+
+// discordColors are colors of the embeds by the severity.
+var discordColors = map[severity]int{
+	severityInfo:    0x3498db,
+	severityOK:      0x2ecc71,
+	severityWarning: 0xf1c40f,
+	severityError:   0xe74c3c,
+}
+
+// discordNotifier posts messages to a Discord webhook.
+type discordNotifier struct {
+	URL       string
+	Username  string
+	AvatarURL string `mapstructure:"avatar_url"`
+	c         *http.Client
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordPayload struct {
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+// discordRateLimit is a response to the rate limited request.
+type discordRateLimit struct {
+	// RetryAfter is in seconds.
+	RetryAfter float64 `json:"retry_after"`
+}
+
+func newDiscordNotifier(cfg interface{}) (*discordNotifier, error) {
+	var n discordNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *discordNotifier) send(ctx context.Context, m message) error {
+	embed := discordEmbed{
+		Title:       truncate(m.subject(""), 256),
+		Description: truncate(m.Body, 4096),
+		Color:       discordColors[m.Event.severity()],
+	}
+
+	if !m.Event.Time.IsZero() {
+		embed.Timestamp = m.Event.Time.UTC().Format(time.RFC3339)
+	}
+
+	if m.Event.OldIP != "" || m.Event.NewIP != "" {
+		embed.Fields = append(embed.Fields,
+			discordField{Name: "Old IP", Value: orDash(m.Event.OldIP), Inline: true},
+			discordField{Name: "New IP", Value: orDash(m.Event.NewIP), Inline: true},
+		)
+	}
+
+	changes := m.Event.changes()
+	for idx, c := range changes {
+		if idx == discordMaxChanges {
+			embed.Fields = append(embed.Fields, discordField{Name: "…", Value: fmt.Sprintf("and %d more", len(changes)-idx)})
+			break
+		}
+
+		embed.Fields = append(embed.Fields,
+			discordField{Name: "Domain", Value: c.Domain, Inline: true},
+			discordField{Name: "Record", Value: c.Record.Type + " " + c.Record.Name, Inline: true},
+			discordField{Name: "Data", Value: truncate(orDash(c.OldData)+" → "+c.Record.Data, 1024), Inline: true},
+		)
+	}
+
+	b, err := json.Marshal(discordPayload{
+		Username:  n.Username,
+		AvatarURL: n.AvatarURL,
+		Embeds:    []discordEmbed{embed},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the payload: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		wait, err := n.post(ctx, b)
+		if err == nil {
+			return nil
+		}
+
+		if wait == 0 || attempt == discordMaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// post sends the payload, if the request is rate limited,
+// it returns for how long to wait before the next try.
+func (n *discordNotifier) post(ctx context.Context, b []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(b))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create a request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.c.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to do a request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		var rl discordRateLimit
+		if err := json.NewDecoder(res.Body).Decode(&rl); err != nil || rl.RetryAfter <= 0 {
+			// fall back to the header
+			rl.RetryAfter, _ = strconv.ParseFloat(res.Header.Get("Retry-After"), 64)
+		}
+
+		wait := time.Duration(rl.RetryAfter * float64(time.Second))
+		if wait <= 0 {
+			wait = time.Second
+		}
+
+		return wait, fmt.Errorf("rate limited, retry after %s", wait)
+	}
+
+	if !misc.Success(res.StatusCode) {
+		return 0, fmt.Errorf("status code is not in a success range: %d", res.StatusCode)
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,144 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestDiscordNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]string{"url": "https://discord.com/api/webhooks/1/x", "username": "ddns"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid url", map[string]string{"url": "oh no"}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newDiscordNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestDiscordNotifierSend(t *testing.T) {
+	tcases := []struct {
+		tname       string
+		rateLimited int
+		body        string
+		header      string
+		statusCode  int
+		calls       int32
+		isErr       bool
+	}{
+		{"ok", 0, "", "", http.StatusNoContent, 1, false},
+		{"ok after rate limit", 2, `{"retry_after": 0.01, "global": false}`, "", http.StatusNoContent, 3, false},
+		{"ok rate limit header", 1, "", "0.01", http.StatusNoContent, 2, false},
+		{"fail rate limited", 10, `{"retry_after": 0.01}`, "", http.StatusNoContent, 4, true},
+		{"fail status", 0, "", "", http.StatusBadRequest, 1, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			var (
+				calls   atomic.Int32
+				payload discordPayload
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(calls.Add(1)) <= tc.rateLimited {
+					if tc.header != "" {
+						w.Header().Set("Retry-After", tc.header)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					_, _ = w.Write([]byte(tc.body))
+					return
+				}
+
+				b, _ := io.ReadAll(r.Body)
+				_ = json.Unmarshal(b, &payload)
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			n, err := newDiscordNotifier(map[string]string{"url": server.URL, "username": "ddns"})
+			is.NoErr(err)
+
+			e := Event{
+				Type:    EventRecordUpdated,
+				Time:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				Domain:  "example.com",
+				Record:  do.Record{Type: "A", Name: "www", Data: "10.0.0.2"},
+				OldData: "10.0.0.1",
+			}
+			err = n.send(context.Background(), message{Event: e, Body: e.String()})
+			is.Equal(calls.Load(), tc.calls)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			is.Equal(payload.Username, "ddns")
+			is.Equal(len(payload.Embeds), 1)
+			embed := payload.Embeds[0]
+			is.Equal(embed.Title, "DDNS: record updated")
+			is.Equal(embed.Color, discordColors[severityInfo])
+			is.Equal(embed.Timestamp, "2024-01-01T12:00:00Z")
+			is.Equal(embed.Fields, []discordField{
+				{Name: "Domain", Value: "example.com", Inline: true},
+				{Name: "Record", Value: "A www", Inline: true},
+				{Name: "Data", Value: "10.0.0.1 → 10.0.0.2", Inline: true},
+			})
+		})
+	}
+}
+
+func TestDiscordNotifierFields(t *testing.T) {
+	is := is.New(t)
+
+	url, requests, close := recordHelper(t, http.StatusNoContent)
+	defer close()
+
+	n, err := newDiscordNotifier(map[string]string{"url": url})
+	is.NoErr(err)
+
+	e := Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}
+	for i := 0; i < 10; i++ {
+		e.Records = append(e.Records, RecordChange{Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}})
+	}
+	is.NoErr(n.send(context.Background(), message{Event: e, Body: e.String()}))
+
+	var p discordPayload
+	is.NoErr(json.Unmarshal(requests()[0].Body, &p))
+
+	fields := p.Embeds[0].Fields
+	is.Equal(len(fields), 2+discordMaxChanges*3+1) // at most 25 fields are allowed
+	is.Equal(fields[0], discordField{Name: "Old IP", Value: "10.0.0.1", Inline: true})
+	is.Equal(fields[len(fields)-1].Value, "and 3 more")
+
+	is.Equal(p.Embeds[0].Color, discordColors[severityInfo])
+}
@@ -0,0 +1,181 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/skibish/ddns/do"
+)
+
+// EventType is a type of the event notifiers can subscribe to.
+type EventType string
+
+// Types of the events.
+const (
+	EventStarted          EventType = "started"
+	EventStopped          EventType = "stopped"
+	EventIPChanged        EventType = "ip_changed"
+	EventRecordUpdated    EventType = "record_updated"
+	EventSyncFailed       EventType = "sync_failed"
+	EventProviderDegraded EventType = "provider_degraded"
+
+	// Events about cleared error conditions.
+	EventSyncRecovered     EventType = "sync_recovered"
+	EventProviderRecovered EventType = "provider_recovered"
+)
+
+// eventTitles are short descriptions of the event types.
+var eventTitles = map[EventType]string{
+	EventStarted:          "started",
+	EventStopped:          "stopped",
+	EventIPChanged:        "IP changed",
+	EventRecordUpdated:    "record updated",
+	EventSyncFailed:       "sync failed",
+	EventProviderDegraded: "IP provider degraded",
+
+	EventSyncRecovered:     "sync recovered",
+	EventProviderRecovered: "IP provider recovered",
+}
+
+// resolves are the error events, which are cleared by the recovery events.
+// Notifiers subscribed to an error event are notified about its recovery as well.
+var resolves = map[EventType]EventType{
+	EventSyncRecovered:     EventSyncFailed,
+	EventProviderRecovered: EventProviderDegraded,
+}
+
+// defaultEvents are the events notifiers are subscribed to,
+// if events are not configured.
+var defaultEvents = []EventType{EventIPChanged, EventSyncFailed, EventProviderDegraded}
+
+// Event is something that happened, which notifiers are told about.
+// Only fields relevant to the type of the event are set.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	OldIP string `json:"old_ip,omitempty"`
+	NewIP string `json:"new_ip,omitempty"`
+
+	// Domain and Record with the new data are set for updated records.
+	Domain  string    `json:"domain,omitempty"`
+	Record  do.Record `json:"record"`
+	OldData string    `json:"old_data,omitempty"`
+
+	// Records are the records changed after IP has changed.
+	Records []RecordChange `json:"records,omitempty"`
+
+	// Provider is a name of the degraded IP provider.
+	Provider string `json:"provider,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// severity is how important the event is.
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityOK
+	severityWarning
+	severityError
+)
+
+// severity returns severity of the event.
+func (e Event) severity() severity {
+	switch e.Type {
+	case EventSyncFailed:
+		return severityError
+	case EventProviderDegraded:
+		return severityWarning
+	case EventSyncRecovered, EventProviderRecovered:
+		return severityOK
+	case EventStopped:
+		if e.Error != "" {
+			return severityError
+		}
+		return severityInfo
+	default:
+		return severityInfo
+	}
+}
+
+// RecordChange is a record created or updated during sync.
+type RecordChange struct {
+	Domain string `json:"domain"`
+	// Record has the new data.
+	Record  do.Record `json:"record"`
+	OldData string    `json:"old_data,omitempty"`
+}
+
+// changes returns the records changed during the event.
+func (e Event) changes() []RecordChange {
+	if e.Type == EventRecordUpdated {
+		return []RecordChange{{Domain: e.Domain, Record: e.Record, OldData: e.OldData}}
+	}
+
+	return e.Records
+}
+
+// Title returns a short description of the event.
+func (e Event) Title() string {
+	if title, ok := eventTitles[e.Type]; ok {
+		return title
+	}
+
+	return string(e.Type)
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case EventStarted:
+		return fmt.Sprintf("ddns has started, current ip is %s", e.NewIP)
+	case EventStopped:
+		return "ddns has stopped"
+	case EventIPChanged:
+		return fmt.Sprintf("ip has been changed from %s to %s", e.OldIP, e.NewIP)
+	case EventRecordUpdated:
+		if e.OldData == "" {
+			return fmt.Sprintf("record %s %s of the domain %s has been created with %q", e.Record.Type, e.Record.Name, e.Domain, e.Record.Data)
+		}
+		return fmt.Sprintf("record %s %s of the domain %s has been changed from %q to %q", e.Record.Type, e.Record.Name, e.Domain, e.OldData, e.Record.Data)
+	case EventSyncFailed:
+		return fmt.Sprintf("failed to sync dns records: %s", e.Error)
+	case EventProviderDegraded:
+		return fmt.Sprintf("ip provider %s is degraded: %s", e.Provider, e.Error)
+	case EventSyncRecovered:
+		return "dns records are synced again"
+	case EventProviderRecovered:
+		return fmt.Sprintf("ip provider %s has recovered", e.Provider)
+	default:
+		return e.Title()
+	}
+}
+
+// condition returns the error condition the event reports or clears,
+// it is empty for other events.
+func (e Event) condition() string {
+	switch e.Type {
+	case EventSyncFailed, EventSyncRecovered:
+		return "sync"
+	case EventProviderDegraded, EventProviderRecovered:
+		return "provider/" + e.Provider
+	default:
+		return ""
+	}
+}
+
+// key identifies the same notifications.
+func (e Event) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s/%s|%s|%s", e.Type, e.OldIP, e.NewIP, e.Domain, e.Record.Type, e.Record.Name, e.Record.Data, e.Error)
+}
+
+// parseEventType checks that the event type exists.
+func parseEventType(s string) (EventType, error) {
+	t := EventType(s)
+	if _, ok := eventTitles[t]; !ok {
+		return "", fmt.Errorf("event %s does not exists", s)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,48 @@
+package notifier
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestEventString(t *testing.T) {
+	tcases := []struct {
+		tname    string
+		event    Event
+		expected string
+	}{
+		{"started", Event{Type: EventStarted, NewIP: "10.0.0.1"}, "ddns has started, current ip is 10.0.0.1"},
+		{"ip changed", Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}, "ip has been changed from 10.0.0.1 to 10.0.0.2"},
+		{
+			"record created",
+			Event{Type: EventRecordUpdated, Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}},
+			`record A www of the domain example.com has been created with "10.0.0.2"`,
+		},
+		{
+			"record updated",
+			Event{Type: EventRecordUpdated, Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}, OldData: "10.0.0.1"},
+			`record A www of the domain example.com has been changed from "10.0.0.1" to "10.0.0.2"`,
+		},
+		{"sync failed", Event{Type: EventSyncFailed, Error: "api is down"}, "failed to sync dns records: api is down"},
+		{"provider degraded", Event{Type: EventProviderDegraded, Provider: "ipify", Error: "timeout"}, "ip provider ipify is degraded: timeout"},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tc.event.String(), tc.expected)
+		})
+	}
+}
+
+func TestEventSeverity(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Event{Type: EventSyncFailed}.severity(), severityError)
+	is.Equal(Event{Type: EventProviderDegraded}.severity(), severityWarning)
+	is.Equal(Event{Type: EventSyncRecovered}.severity(), severityOK)
+	is.Equal(Event{Type: EventStopped, Error: "oops"}.severity(), severityError)
+	is.Equal(Event{Type: EventIPChanged}.severity(), severityInfo)
+}
@@ -0,0 +1,139 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// googleChatMaxChanges is how many changed records are listed,
+// so that the card fits into the size limit of the message.
+const googleChatMaxChanges = 20
+
+// googleChatNotifier posts cards to a Google Chat space webhook.
+type googleChatNotifier struct {
+	URL string
+	// ThreadKey groups the messages in a thread, if set.
+	ThreadKey string `mapstructure:"thread_key"`
+	c         *http.Client
+}
+
+type googleChatText struct {
+	Text string `json:"text"`
+}
+
+type googleChatDecoratedText struct {
+	TopLabel string `json:"topLabel"`
+	Text     string `json:"text"`
+}
+
+type googleChatWidget struct {
+	TextParagraph *googleChatText          `json:"textParagraph,omitempty"`
+	DecoratedText *googleChatDecoratedText `json:"decoratedText,omitempty"`
+}
+
+type googleChatSection struct {
+	Header  string             `json:"header,omitempty"`
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type googleChatCard struct {
+	Header   googleChatHeader    `json:"header"`
+	Sections []googleChatSection `json:"sections"`
+}
+
+type googleChatCardV2 struct {
+	CardID string         `json:"cardId"`
+	Card   googleChatCard `json:"card"`
+}
+
+type googleChatThread struct {
+	ThreadKey string `json:"threadKey"`
+}
+
+type googleChatPayload struct {
+	// Text is the subject, the card renders the body, so that it is not shown twice.
+	Text    string             `json:"text"`
+	CardsV2 []googleChatCardV2 `json:"cardsV2"`
+	Thread  *googleChatThread  `json:"thread,omitempty"`
+}
+
+func newGoogleChatNotifier(cfg interface{}) (*googleChatNotifier, error) {
+	var n googleChatNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	if n.ThreadKey != "" {
+		// replies to the thread with the key or starts it
+		u, err := url.Parse(n.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse url: %w", err)
+		}
+		q := u.Query()
+		q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+		u.RawQuery = q.Encode()
+		n.URL = u.String()
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *googleChatNotifier) send(ctx context.Context, m message) error {
+	subject := m.subject("")
+
+	card := googleChatCard{
+		Header: googleChatHeader{Title: subject, Subtitle: hostname()},
+		Sections: []googleChatSection{{
+			Widgets: []googleChatWidget{{TextParagraph: &googleChatText{Text: html.EscapeString(m.Body)}}},
+		}},
+	}
+
+	if m.Event.OldIP != "" || m.Event.NewIP != "" {
+		card.Sections[0].Widgets = append(card.Sections[0].Widgets,
+			googleChatWidget{DecoratedText: &googleChatDecoratedText{TopLabel: "Old IP", Text: orDash(m.Event.OldIP)}},
+			googleChatWidget{DecoratedText: &googleChatDecoratedText{TopLabel: "New IP", Text: orDash(m.Event.NewIP)}},
+		)
+	}
+
+	if changes := m.Event.changes(); len(changes) > 0 {
+		section := googleChatSection{Header: "Records"}
+		for idx, c := range changes {
+			if idx == googleChatMaxChanges {
+				section.Widgets = append(section.Widgets, googleChatWidget{TextParagraph: &googleChatText{Text: fmt.Sprintf("and %d more", len(changes)-idx)}})
+				break
+			}
+			section.Widgets = append(section.Widgets, googleChatWidget{DecoratedText: &googleChatDecoratedText{
+				TopLabel: html.EscapeString(fmt.Sprintf("%s %s (%s)", c.Record.Type, c.Record.Name, c.Domain)),
+				Text:     html.EscapeString(orDash(c.OldData) + " → " + c.Record.Data),
+			}})
+		}
+		card.Sections = append(card.Sections, section)
+	}
+
+	p := googleChatPayload{
+		Text:    subject,
+		CardsV2: []googleChatCardV2{{CardID: "ddns", Card: card}},
+	}
+	if n.ThreadKey != "" {
+		p.Thread = &googleChatThread{ThreadKey: n.ThreadKey}
+	}
+
+	return postJSON(ctx, n.c, n.URL, p)
+}
@@ -0,0 +1,143 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestGoogleChatNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		url   string
+		isErr bool
+	}{
+		{
+			tname: "ok",
+			cfg:   map[string]string{"url": "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&token=t"},
+			url:   "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&token=t",
+		},
+		{
+			tname: "ok thread",
+			cfg:   map[string]string{"url": "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&token=t", "thread_key": "ddns"},
+			url:   "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&messageReplyOption=REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD&token=t",
+		},
+		{tname: "invalid config", cfg: "something unexpected", isErr: true},
+		{tname: "invalid url", cfg: map[string]string{"url": "oh no"}, isErr: true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			n, err := newGoogleChatNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(n.URL, tc.url)
+		})
+	}
+}
+
+func TestGoogleChatNotifierSend(t *testing.T) {
+	defer func(h func() string) { hostname = h }(hostname)
+	hostname = func() string { return "router" }
+
+	tcases := []struct {
+		tname      string
+		statusCode int
+		threadKey  string
+		event      Event
+		sections   int
+		widgets    int
+		isErr      bool
+	}{
+		{
+			tname:      "ok ip changed",
+			statusCode: http.StatusOK,
+			threadKey:  "ddns",
+			event: Event{
+				Type:  EventIPChanged,
+				OldIP: "10.0.0.1",
+				NewIP: "10.0.0.2",
+				Records: []RecordChange{
+					{Domain: "example.com", Record: do.Record{Type: "TXT", Name: "<demo>", Data: "ip & more"}},
+				},
+			},
+			sections: 2,
+			widgets:  3,
+		},
+		{
+			tname:      "ok sync failed",
+			statusCode: http.StatusOK,
+			event:      Event{Type: EventSyncFailed, Error: "<html> is not json"},
+			sections:   1,
+			widgets:    1,
+		},
+		{
+			tname:      "fail status",
+			statusCode: http.StatusForbidden,
+			event:      Event{Type: EventStarted},
+			isErr:      true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			n, err := newGoogleChatNotifier(map[string]string{"url": url, "thread_key": tc.threadKey})
+			is.NoErr(err)
+
+			err = n.send(context.Background(), message{Event: tc.event, Body: tc.event.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			reqs := requests()
+			is.Equal(len(reqs), 1)
+
+			var p googleChatPayload
+			is.NoErr(json.Unmarshal(reqs[0].Body, &p))
+			is.Equal(p.Text, "DDNS: "+tc.event.Title()) // the body is only in the card
+			is.Equal(len(p.CardsV2), 1)
+
+			card := p.CardsV2[0].Card
+			is.Equal(card.Header.Title, "DDNS: "+tc.event.Title())
+			is.Equal(card.Header.Subtitle, "router")
+			is.Equal(len(card.Sections), tc.sections)
+			is.Equal(len(card.Sections[0].Widgets), tc.widgets)
+
+			if tc.threadKey != "" {
+				is.Equal(p.Thread.ThreadKey, tc.threadKey)
+			} else {
+				is.Equal(p.Thread, nil)
+			}
+
+			if tc.event.Type == EventSyncFailed {
+				is.Equal(card.Sections[0].Widgets[0].TextParagraph.Text, "failed to sync dns records: &lt;html&gt; is not json")
+			}
+			if len(tc.event.Records) > 0 {
+				d := card.Sections[1].Widgets[0].DecoratedText
+				is.Equal(d.TopLabel, "TXT &lt;demo&gt; (example.com)")
+				is.Equal(d.Text, "- → ip &amp; more")
+			}
+		})
+	}
+}
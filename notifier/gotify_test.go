@@ -1,42 +1,42 @@
 package notifier
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
 	"github.com/matryer/is"
-	"github.com/sirupsen/logrus"
 )
 
-func TestGotifyHookNew(t *testing.T) {
+func TestGotifyNotifierNew(t *testing.T) {
 	is := is.New(t)
 
-	if _, err := newGotifyhook("cfg"); err == nil {
+	if _, err := newGotifyNotifier("cfg"); err == nil {
 		is.Fail() // should be error, but got nothing
 	}
 
 	m := make(map[interface{}]interface{})
 	m["app_url"] = 123
-	if _, err := newGotifyhook(m); err == nil {
+	if _, err := newGotifyNotifier(m); err == nil {
 		is.Fail() // should fail because not a valid string
 		return
 	}
 
 	m = make(map[interface{}]interface{})
 	m["app_url"] = "https://gotify.example.com/"
-	hook, err := newGotifyhook(m)
+	n, err := newGotifyNotifier(m)
 	is.NoErr(err)
-	is.Equal(hook.AppURL, "https://gotify.example.com")
-	is.Equal(hook.Title, "DDNS")
+	is.Equal(n.AppURL, "https://gotify.example.com")
+	is.Equal(n.Title, "DDNS")
 
 	m["app_url"] = "something bad"
-	if _, err := newGotifyhook(m); err == nil {
+	if _, err := newGotifyNotifier(m); err == nil {
 		is.Fail() // should fail because incorrect url
 	}
 
 }
 
-func TestGotifyFire(t *testing.T) {
+func TestGotifyNotifierSend(t *testing.T) {
 	tcases := []struct {
 		tname      string
 		statusCode int
@@ -54,7 +54,7 @@ func TestGotifyFire(t *testing.T) {
 			url, close := httpHelper(t, tc.tname, nil, tc.statusCode)
 			defer close()
 
-			hook, err := newGotifyhook(map[string]interface{}{
+			n, err := newGotifyNotifier(map[string]interface{}{
 				"app_url": url,
 				"token":   "1234",
 				"title":   "DDNS",
@@ -62,11 +62,10 @@ func TestGotifyFire(t *testing.T) {
 			is.NoErr(err)
 
 			if tc.tname == "fail do" {
-				hook.AppURL = " "
+				n.AppURL = " "
 			}
 
-			entry := logrus.Entry{Message: tc.tname, Level: logrus.InfoLevel}
-			err = hook.Fire(&entry)
+			err = n.send(context.Background(), message{Body: tc.tname})
 
 			if tc.isErr {
 				if err == nil {
@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/skibish/ddns/misc"
+)
+
+// postJSON sends the payload encoded as JSON to the url.
+func postJSON(ctx context.Context, c *http.Client, url string, payload interface{}) error {
+	return requestJSON(ctx, c, http.MethodPost, url, nil, payload)
+}
+
+// requestJSON sends the payload encoded as JSON with the method and additional headers.
+func requestJSON(ctx context.Context, c *http.Client, method, url string, headers map[string]string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode the payload: %w", err)
+	}
+
+	h := map[string]string{"Content-Type": "application/json"}
+	for k, v := range headers {
+		h[k] = v
+	}
+
+	return doRequest(ctx, c, method, url, h, b)
+}
+
+// doRequest sends the body with the method and headers.
+func doRequest(ctx context.Context, c *http.Client, method, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create a request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to do a request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if !misc.Success(res.StatusCode) {
+		return fmt.Errorf("status code is not in a success range: %d", res.StatusCode)
+	}
+
+	return nil
+}
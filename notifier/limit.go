@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultRatePeriod is a period of the rate limit.
+const defaultRatePeriod = time.Hour
+
+// limiter suppresses repeated notifications and limits their rate,
+// so that flapping providers or an unavailable API don't spam the channel.
+type limiter struct {
+	// window is for how long the same notification is suppressed.
+	window time.Duration
+	// rate is a maximum number of notifications per period.
+	rate   int
+	period time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// seen are the times notifications were last allowed.
+	seen map[string]time.Time
+	// open are the error conditions which have been notified and have not been cleared yet.
+	open map[string]bool
+	// sent are the times of the notifications allowed during the last period.
+	sent []time.Time
+}
+
+// newLimiter returns a limiter, zero window or rate disable deduplication or rate limit.
+// It returns nil, if both are disabled.
+func newLimiter(window time.Duration, rate int, period time.Duration) *limiter {
+	if window <= 0 && rate <= 0 {
+		return nil
+	}
+
+	if period <= 0 {
+		period = defaultRatePeriod
+	}
+
+	return &limiter{
+		window: window,
+		rate:   rate,
+		period: period,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+		open:   make(map[string]bool),
+	}
+}
+
+// allow reports whether the notification about the event should be sent.
+// Recovery events are sent only if the error has been notified,
+// and they are not rate limited, so that the error is not left unresolved.
+func (l *limiter) allow(e Event) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cond := e.condition()
+
+	if _, ok := resolves[e.Type]; ok {
+		if !l.open[cond] {
+			return false
+		}
+
+		// the next error is notified right away
+		delete(l.open, cond)
+		delete(l.seen, cond)
+		l.record(now)
+		return true
+	}
+
+	key := cond
+	if key == "" {
+		key = e.key()
+	}
+
+	if last, ok := l.seen[key]; ok && l.window > 0 && now.Sub(last) < l.window {
+		return false
+	}
+
+	if l.rate > 0 && l.count(now) >= l.rate {
+		return false
+	}
+
+	l.seen[key] = now
+	if cond != "" {
+		l.open[cond] = true
+	}
+	l.record(now)
+
+	return true
+}
+
+// count returns the number of notifications sent during the last period.
+func (l *limiter) count(now time.Time) int {
+	idx := 0
+	for idx < len(l.sent) && now.Sub(l.sent[idx]) >= l.period {
+		idx++
+	}
+	l.sent = l.sent[idx:]
+
+	return len(l.sent)
+}
+
+func (l *limiter) record(now time.Time) {
+	if l.rate > 0 {
+		l.sent = append(l.sent, now)
+	}
+}
@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestLimiter(t *testing.T) {
+	failed := Event{Type: EventSyncFailed, Error: "api is down"}
+	recovered := Event{Type: EventSyncRecovered}
+	changed := Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}
+	changedBack := Event{Type: EventIPChanged, OldIP: "10.0.0.2", NewIP: "10.0.0.1"}
+	degraded := Event{Type: EventProviderDegraded, Provider: "ipify"}
+
+	type step struct {
+		after   time.Duration
+		event   Event
+		allowed bool
+	}
+
+	tcases := []struct {
+		tname  string
+		window time.Duration
+		rate   int
+		steps  []step
+	}{
+		{
+			tname:  "dedup",
+			window: time.Hour,
+			steps: []step{
+				{0, failed, true},
+				{time.Minute, failed, false},
+				{time.Minute, Event{Type: EventSyncFailed, Error: "timeout"}, false}, // the same condition
+				{time.Hour, failed, true}, // reminder after the window
+				{0, degraded, true},       // another condition
+				{0, changed, true},
+				{0, changedBack, true},
+				{0, changed, false},
+			},
+		},
+		{
+			tname:  "resolved",
+			window: time.Hour,
+			steps: []step{
+				{0, recovered, false}, // nothing to resolve
+				{0, failed, true},
+				{time.Minute, failed, false},
+				{time.Minute, recovered, true},
+				{0, recovered, false},
+				{time.Minute, failed, true}, // new failure is notified right away
+			},
+		},
+		{
+			tname: "rate limit",
+			rate:  2,
+			steps: []step{
+				{0, changed, true},
+				{0, failed, true},
+				{time.Minute, changedBack, false},
+				{0, recovered, true}, // recovery is not limited
+				{time.Hour, changedBack, true},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			l := newLimiter(tc.window, tc.rate, time.Hour)
+			l.now = func() time.Time { return now }
+
+			for _, s := range tc.steps {
+				now = now.Add(s.after)
+				is.Equal(l.allow(s.event), s.allowed)
+			}
+		})
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	is := is.New(t)
+	is.True(newLimiter(0, 0, 0) == nil)
+}
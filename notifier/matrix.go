@@ -0,0 +1,124 @@
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// matrixNotifier sends messages to a Matrix room using the client-server API.
+type matrixNotifier struct {
+	Homeserver  string
+	AccessToken string `mapstructure:"access_token"`
+	RoomID      string `mapstructure:"room_id"`
+	// MsgType is m.text or m.notice, defaults to m.text.
+	MsgType string `mapstructure:"msgtype"`
+	// HTML enables formatted body of the messages.
+	HTML bool
+	c    *http.Client
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+func newMatrixNotifier(cfg interface{}) (*matrixNotifier, error) {
+	var n matrixNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.Homeserver) {
+		return nil, errors.New("homeserver is not a valid url")
+	}
+
+	if n.AccessToken == "" {
+		return nil, errors.New("access_token can't be empty")
+	}
+
+	if n.RoomID == "" {
+		return nil, errors.New("room_id can't be empty")
+	}
+
+	switch n.MsgType {
+	case "":
+		n.MsgType = "m.text"
+	case "m.text", "m.notice":
+	default:
+		return nil, fmt.Errorf("msgtype %s is not supported", n.MsgType)
+	}
+
+	n.Homeserver = strings.TrimSuffix(n.Homeserver, "/")
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *matrixNotifier) send(ctx context.Context, m message) error {
+	subject := m.subject("")
+	changes := m.Event.changes()
+
+	msg := matrixMessage{
+		MsgType: n.MsgType,
+		Body:    subject + "\n" + m.Body,
+	}
+	if len(changes) > 0 {
+		msg.Body += "\n\n" + recordsTable(changes)
+	}
+
+	if n.HTML {
+		msg.Format = "org.matrix.custom.html"
+		msg.FormattedBody = matrixHTML(subject, m.Body, changes)
+	}
+
+	txnID, err := matrixTxnID(m.Event)
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", n.Homeserver, url.PathEscape(n.RoomID), txnID)
+	return requestJSON(ctx, n.c, http.MethodPut, u, map[string]string{"Authorization": "Bearer " + n.AccessToken}, msg)
+}
+
+// matrixTxnID returns a transaction ID derived from the event,
+// so that retried deliveries of the same event are not duplicated in the room.
+func matrixTxnID(e Event) (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the event: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return "ddns-" + hex.EncodeToString(sum[:16]), nil
+}
+
+// matrixHTML formats the message as HTML.
+func matrixHTML(subject, body string, changes []RecordChange) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<strong>%s</strong><br>%s", html.EscapeString(subject), strings.ReplaceAll(html.EscapeString(body), "\n", "<br>"))
+
+	if len(changes) > 0 {
+		b.WriteString("<table><tr><th>Domain</th><th>Record</th><th>Old</th><th>New</th></tr>")
+		for _, c := range changes {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s %s</td><td>%s</td><td>%s</td></tr>",
+				html.EscapeString(c.Domain), html.EscapeString(c.Record.Type), html.EscapeString(c.Record.Name),
+				html.EscapeString(orDash(c.OldData)), html.EscapeString(c.Record.Data))
+		}
+		b.WriteString("</table>")
+	}
+
+	return b.String()
+}
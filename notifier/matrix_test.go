@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestMatrixNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]interface{}{"homeserver": "https://matrix.org/", "access_token": "token", "room_id": "!room:matrix.org"}, false},
+		{"ok notice", map[string]interface{}{"homeserver": "https://matrix.org", "access_token": "token", "room_id": "!room:matrix.org", "msgtype": "m.notice"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid homeserver", map[string]interface{}{"homeserver": "oh no", "access_token": "token", "room_id": "!room:matrix.org"}, true},
+		{"no token", map[string]interface{}{"homeserver": "https://matrix.org", "room_id": "!room:matrix.org"}, true},
+		{"no room", map[string]interface{}{"homeserver": "https://matrix.org", "access_token": "token"}, true},
+		{"invalid msgtype", map[string]interface{}{"homeserver": "https://matrix.org", "access_token": "token", "room_id": "!room:matrix.org", "msgtype": "m.image"}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newMatrixNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestMatrixNotifierSend(t *testing.T) {
+	tcases := []struct {
+		tname      string
+		html       bool
+		statusCode int
+		isErr      bool
+	}{
+		{"ok plain", false, http.StatusOK, false},
+		{"ok html", true, http.StatusOK, false},
+		{"fail status", false, http.StatusForbidden, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			n, err := newMatrixNotifier(map[string]interface{}{
+				"homeserver":   url,
+				"access_token": "secret",
+				"room_id":      "!room:example.com",
+				"html":         tc.html,
+			})
+			is.NoErr(err)
+
+			e := Event{
+				Type:    EventRecordUpdated,
+				Time:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				Domain:  "example.com",
+				Record:  do.Record{Type: "TXT", Name: "demo", Data: "<b>"},
+				OldData: "10.0.0.1",
+			}
+			err = n.send(context.Background(), message{Event: e, Body: e.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			// retried delivery uses the same transaction
+			is.NoErr(n.send(context.Background(), message{Event: e, Body: e.String()}))
+
+			reqs := requests()
+			is.Equal(len(reqs), 2)
+			is.Equal(reqs[0].Method, http.MethodPut)
+			is.True(strings.HasPrefix(reqs[0].URL, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/ddns-"))
+			is.Equal(reqs[0].URL, reqs[1].URL)
+			is.Equal(reqs[0].Header.Get("Authorization"), "Bearer secret")
+
+			var msg matrixMessage
+			is.NoErr(json.Unmarshal(reqs[0].Body, &msg))
+			is.Equal(msg.MsgType, "m.text")
+			is.True(strings.HasPrefix(msg.Body, "DDNS: record updated\nrecord TXT demo"))
+			is.True(strings.Contains(msg.Body, "example.com  TXT demo  10.0.0.1  <b>"))
+
+			if !tc.html {
+				is.Equal(msg.Format, "")
+				is.Equal(msg.FormattedBody, "")
+				return
+			}
+			is.Equal(msg.Format, "org.matrix.custom.html")
+			is.True(strings.HasPrefix(msg.FormattedBody, "<strong>DDNS: record updated</strong><br>"))
+			is.True(strings.Contains(msg.FormattedBody, "<td>&lt;b&gt;</td>")) // data is escaped
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// mattermostNotifier posts messages to a Mattermost incoming webhook.
+type mattermostNotifier struct {
+	URL      string
+	Channel  string
+	Username string
+	IconURL  string `mapstructure:"icon_url"`
+	c        *http.Client
+}
+
+type mattermostPayload struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+	IconURL  string `json:"icon_url,omitempty"`
+}
+
+func newMattermostNotifier(cfg interface{}) (*mattermostNotifier, error) {
+	var n mattermostNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *mattermostNotifier) send(ctx context.Context, m message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#### %s\n%s", m.subject(""), m.Body)
+
+	if m.Event.OldIP != "" || m.Event.NewIP != "" {
+		fmt.Fprintf(&b, "\n\n**Old IP:** %s\n**New IP:** %s", orDash(m.Event.OldIP), orDash(m.Event.NewIP))
+	}
+
+	if changes := m.Event.changes(); len(changes) > 0 {
+		b.WriteString("\n\n" + recordsMarkdownTable(changes))
+	}
+
+	return postJSON(ctx, n.c, n.URL, mattermostPayload{
+		Text:     b.String(),
+		Channel:  n.Channel,
+		Username: n.Username,
+		IconURL:  n.IconURL,
+	})
+}
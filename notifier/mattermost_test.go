@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestMattermostNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]string{"url": "https://mattermost.example.com/hooks/xxx", "username": "ddns"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid url", map[string]string{"url": "oh no"}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newMattermostNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestMattermostNotifierSend(t *testing.T) {
+	tcases := []struct {
+		tname      string
+		statusCode int
+		event      Event
+		expected   string
+		isErr      bool
+	}{
+		{
+			tname:      "ok record updated",
+			statusCode: http.StatusOK,
+			event: Event{
+				Type:    EventRecordUpdated,
+				Domain:  "example.com",
+				Record:  do.Record{Type: "TXT", Name: "demo", Data: "a|b"},
+				OldData: "c",
+			},
+			expected: "#### DDNS: record updated\n" +
+				`record TXT demo of the domain example.com has been changed from "c" to "a|b"` + "\n\n" +
+				"| Domain | Record | Old | New |\n" +
+				"|---|---|---|---|\n" +
+				"| example.com | TXT demo | c | a\\|b |",
+		},
+		{
+			tname:      "ok ip changed",
+			statusCode: http.StatusOK,
+			event:      Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"},
+			expected: "#### DDNS: IP changed\n" +
+				"ip has been changed from 10.0.0.1 to 10.0.0.2\n\n" +
+				"**Old IP:** 10.0.0.1\n**New IP:** 10.0.0.2",
+		},
+		{
+			tname:      "fail status",
+			statusCode: http.StatusInternalServerError,
+			event:      Event{Type: EventStopped},
+			isErr:      true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			n, err := newMattermostNotifier(map[string]string{"url": url, "username": "ddns"})
+			is.NoErr(err)
+
+			err = n.send(context.Background(), message{Event: tc.event, Body: tc.event.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			reqs := requests()
+			is.Equal(len(reqs), 1)
+
+			var p mattermostPayload
+			is.NoErr(json.Unmarshal(reqs[0].Body, &p))
+			is.Equal(p.Username, "ddns")
+			is.Equal(p.Text, tc.expected)
+		})
+	}
+}
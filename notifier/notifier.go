@@ -1,43 +1,258 @@
 package notifier
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
-	"github.com/sirupsen/logrus"
 )
 
-type hookType struct {
+// Notifier notifies about events.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+	// Close delivers pending notifications until ctx is done.
+	Close(ctx context.Context) error
+}
+
+// message is what is delivered to the channel.
+type message struct {
+	Event Event
+	// Subject is empty, if it is not set by a template.
+	Subject string
+	Body    string
+}
+
+// subject returns the subject of the message,
+// if it is not set, fallback or the title of the event is returned.
+func (m message) subject(fallback string) string {
+	if m.Subject != "" {
+		return m.Subject
+	}
+
+	if fallback != "" {
+		return fallback
+	}
+
+	return "DDNS: " + m.Event.Title()
+}
+
+// sender delivers messages to a single channel (chat, mailbox, etc.).
+type sender interface {
+	send(ctx context.Context, m message) error
+}
+
+// config is a configuration common for all notifiers.
+type config struct {
 	Type string
+	// Events are the types of the events to notify about.
+	Events []string
+	// Templates are templates of the messages by the event type.
+	Templates map[string]templateConfig
+
+	// QueueSize is a maximum number of undelivered notifications.
+	QueueSize int `mapstructure:"queue_size"`
+	// Retries is a number of retries of a failed delivery,
+	// nil means the default, 0 disables retries.
+	Retries *int
+	// RetryDelay is a delay before the first retry, it doubles with every retry.
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// Spool is a file where undelivered notifications are kept between restarts.
+	Spool string
+
+	// DedupWindow is for how long the same notification is not repeated.
+	DedupWindow time.Duration `mapstructure:"dedup_window"`
+	// RateLimit is a maximum number of notifications per RatePeriod.
+	RateLimit  int           `mapstructure:"rate_limit"`
+	RatePeriod time.Duration `mapstructure:"rate_period"`
+}
+
+// notifier delivers messages about the subscribed events using the sender.
+type notifier struct {
+	name      string
+	events    map[EventType]bool
+	templates map[EventType]messageTemplate
+	sender    sender
+}
+
+// New returns a notifier for the configuration,
+// notifications are delivered asynchronously.
+func New(cfg interface{}) (Notifier, error) {
+	c, err := decodeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := newNotifier(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := defaultRetries
+	if c.Retries != nil {
+		retries = *c.Retries
+	}
+
+	q := newQueue(n, c.QueueSize, retries, c.RetryDelay, c.Spool)
+	q.limiter = newLimiter(c.DedupWindow, c.RateLimit, c.RatePeriod)
+
+	return q, nil
+}
+
+// decodeConfig decodes the configuration common for all notifiers.
+func decodeConfig(cfg interface{}) (config, error) {
+	var c config
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &c,
+	})
+	if err != nil {
+		return c, fmt.Errorf("failed to create a decoder: %w", err)
+	}
+
+	if err := d.Decode(cfg); err != nil {
+		return c, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	return c, nil
 }
 
-// GetHook returns initialized notifier as hook for Logrus
-func GetHook(cfg interface{}) (logrus.Hook, error) {
-	var ht hookType
-	if err := mapstructure.Decode(cfg, &ht); err != nil {
+// newNotifier returns a notifier which delivers notifications synchronously.
+func newNotifier(c config, cfg interface{}) (*notifier, error) {
+	s, err := newSender(c.Type, cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	switch strings.ToLower(ht.Type) {
+	templates, err := parseTemplates(c.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &notifier{
+		name:      strings.ToLower(c.Type),
+		events:    make(map[EventType]bool),
+		templates: templates,
+		sender:    s,
+	}
+
+	events := defaultEvents
+	if len(c.Events) > 0 {
+		events = make([]EventType, 0, len(c.Events))
+		for _, e := range c.Events {
+			t, err := parseEventType(strings.ToLower(e))
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, t)
+		}
+	}
+
+	for _, e := range events {
+		n.events[e] = true
+	}
+
+	return n, nil
+}
+
+// newSender returns the sender of the type.
+func newSender(t string, cfg interface{}) (sender, error) {
+	switch strings.ToLower(t) {
 	case "smtp":
-		return newSMTPHook(cfg)
+		return newSMTPNotifier(cfg)
 	case "telegram":
-		return newTelegramHook(cfg)
+		return newTelegramNotifier(cfg)
 	case "gotify":
-		return newGotifyhook(cfg)
+		return newGotifyNotifier(cfg)
+	case "slack":
+		return newSlackNotifier(cfg)
+	case "mattermost":
+		return newMattermostNotifier(cfg)
+	case "discord":
+		return newDiscordNotifier(cfg)
+	case "matrix":
+		return newMatrixNotifier(cfg)
+	case "ntfy":
+		return newNtfyNotifier(cfg)
+	case "pushover":
+		return newPushoverNotifier(cfg)
+	case "webhook":
+		return newWebhookNotifier(cfg)
+	case "teams":
+		return newTeamsNotifier(cfg)
+	case "googlechat":
+		return newGoogleChatNotifier(cfg)
 	default:
-		return nil, fmt.Errorf("notifier %s does not exists", ht.Type)
+		return nil, fmt.Errorf("notifier %s does not exists", t)
+	}
+}
+
+// Notify sends a message about the event, if the notifier is subscribed to it.
+func (n *notifier) Notify(ctx context.Context, e Event) error {
+	if !n.subscribed(e.Type) {
+		return nil
+	}
+
+	m, err := n.templates[e.Type].render(e)
+	if err != nil {
+		return fmt.Errorf("%s: %w", n.name, err)
 	}
+
+	if err = n.sender.send(ctx, m); err != nil {
+		return fmt.Errorf("%s: %w", n.name, err)
+	}
+
+	return nil
+}
+
+// subscribed reports whether the notifier is subscribed to the events of the type,
+// recovery events are delivered to the subscribers of the error events as well.
+func (n *notifier) subscribed(t EventType) bool {
+	if n.events[t] {
+		return true
+	}
+
+	resolved, ok := resolves[t]
+	return ok && n.events[resolved]
+}
+
+// Close does nothing, notifications are delivered synchronously.
+func (n *notifier) Close(context.Context) error {
+	return nil
+}
+
+// Group notifies all notifiers in it.
+type Group []Notifier
+
+// Notify notifies all notifiers about the event,
+// a failure of one notifier doesn't stop the others.
+func (g Group) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, n := range g {
+		if err := n.Notify(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func allowedLevels() []logrus.Level {
-	// ignore DEBUG messages
-	return []logrus.Level{
-		logrus.InfoLevel,
-		logrus.WarnLevel,
-		logrus.ErrorLevel,
-		logrus.FatalLevel,
-		logrus.PanicLevel,
+// Close closes all notifiers in parallel.
+func (g Group) Close(ctx context.Context) error {
+	errs := make([]error, len(g))
+
+	var wg sync.WaitGroup
+	for idx, n := range g {
+		wg.Add(1)
+		go func(idx int, n Notifier) {
+			defer wg.Done()
+			errs[idx] = n.Close(ctx)
+		}(idx, n)
 	}
+	wg.Wait()
+
+	return errors.Join(errs...)
 }
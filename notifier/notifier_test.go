@@ -1,8 +1,12 @@
 package notifier
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/matryer/is"
@@ -23,7 +27,41 @@ func httpHelper(t *testing.T, response string, headers map[string]string, status
 	return server.URL, server.Close
 }
 
-func TestGetHook(t *testing.T) {
+type request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// recordHelper starts a server, which responds with the status code and records requests.
+func recordHelper(t *testing.T, statusCode int) (string, func() []request, func()) {
+	var (
+		mu       sync.Mutex
+		requests []request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		requests = append(requests, request{Method: r.Method, URL: r.URL.String(), Header: r.Header.Clone(), Body: body})
+		mu.Unlock()
+
+		w.WriteHeader(statusCode)
+	}))
+
+	recorded := func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]request(nil), requests...)
+	}
+
+	return server.URL, recorded, server.Close
+}
+
+func TestNew(t *testing.T) {
 	tcases := []struct {
 		tname  string
 		config interface{}
@@ -52,6 +90,75 @@ func TestGetHook(t *testing.T) {
 				"app_url": "https://gotify.example.com/",
 			},
 		},
+		{
+			tname: "ok events",
+			config: map[string]interface{}{
+				"type":    "telegram",
+				"chat_id": "someid",
+				"token":   "1234",
+				"events":  []string{"started", "IP_CHANGED"},
+			},
+		},
+		{
+			tname: "fail unknown event",
+			config: map[string]interface{}{
+				"type":    "telegram",
+				"chat_id": "someid",
+				"token":   "1234",
+				"events":  []string{"ip_updated"},
+			},
+			isErr: true,
+		},
+		{
+			tname: "ok slack",
+			config: map[string]string{
+				"type": "slack",
+				"url":  "https://hooks.slack.com/services/T/B/X",
+			},
+		},
+		{
+			tname: "ok mattermost",
+			config: map[string]string{
+				"type": "mattermost",
+				"url":  "https://mattermost.example.com/hooks/xxx",
+			},
+		},
+		{
+			tname: "ok ntfy",
+			config: map[string]string{
+				"type": "ntfy",
+				"url":  "https://ntfy.sh/ddns",
+			},
+		},
+		{
+			tname: "ok pushover",
+			config: map[string]string{
+				"type":  "pushover",
+				"user":  "ukey",
+				"token": "atoken",
+			},
+		},
+		{
+			tname: "ok webhook",
+			config: map[string]string{
+				"type": "webhook",
+				"url":  "https://example.com/hook",
+			},
+		},
+		{
+			tname: "ok teams",
+			config: map[string]string{
+				"type": "teams",
+				"url":  "https://example.webhook.office.com/webhookb2/xxx",
+			},
+		},
+		{
+			tname: "ok googlechat",
+			config: map[string]string{
+				"type": "googlechat",
+				"url":  "https://chat.googleapis.com/v1/spaces/AAA/messages?key=k&token=t",
+			},
+		},
 		{
 			tname:  "fail decode type",
 			config: map[string]interface{}{"type": 1234},
@@ -68,7 +175,7 @@ func TestGetHook(t *testing.T) {
 		t.Run(tc.tname, func(t *testing.T) {
 			is := is.New(t)
 
-			_, err := GetHook(tc.config)
+			n, err := New(tc.config)
 			if tc.isErr {
 				if err == nil {
 					is.Fail() // should be error
@@ -77,6 +184,116 @@ func TestGetHook(t *testing.T) {
 			}
 
 			is.NoErr(err)
+			is.NoErr(n.Close(context.Background()))
+		})
+	}
+}
+
+// newTestNotifier returns a notifier which delivers notifications synchronously.
+func newTestNotifier(cfg interface{}) (*notifier, error) {
+	c, err := decodeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newNotifier(c, cfg)
+}
+
+func TestNewRetries(t *testing.T) {
+	tcases := []struct {
+		tname   string
+		retries interface{}
+		exp     int
+	}{
+		{"default", nil, defaultRetries},
+		{"disabled", 0, 0},
+		{"set", 2, 2},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			cfg := map[string]interface{}{"type": "telegram", "chat_id": "someid", "token": "1234"}
+			if tc.retries != nil {
+				cfg["retries"] = tc.retries
+			}
+
+			n, err := New(cfg)
+			is.NoErr(err)
+
+			q := n.(*queue)
+			is.Equal(q.retries, tc.exp)
+			is.NoErr(q.Close(context.Background()))
+		})
+	}
+}
+
+type fakeSender struct {
+	messages []message
+	err      error
+}
+
+func (s *fakeSender) send(ctx context.Context, m message) error {
+	s.messages = append(s.messages, m)
+	return s.err
+}
+
+func TestNotifierNotify(t *testing.T) {
+	tcases := []struct {
+		tname    string
+		events   []string
+		event    Event
+		messages int
+	}{
+		{"ok default", nil, Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}, 1},
+		{"ok not subscribed by default", nil, Event{Type: EventStarted, NewIP: "10.0.0.1"}, 0},
+		{"ok subscribed", []string{"started"}, Event{Type: EventStarted, NewIP: "10.0.0.1"}, 1},
+		{"ok not subscribed", []string{"started"}, Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}, 0},
+		{"ok recovery of the subscribed error", []string{"sync_failed"}, Event{Type: EventSyncRecovered}, 1},
+		{"ok recovery not subscribed", []string{"started"}, Event{Type: EventSyncRecovered}, 0},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			n, err := newTestNotifier(map[string]interface{}{
+				"type":    "telegram",
+				"chat_id": "someid",
+				"token":   "1234",
+				"events":  tc.events,
+			})
+			is.NoErr(err)
+
+			s := &fakeSender{}
+			n.sender = s
+
+			is.NoErr(n.Notify(context.Background(), tc.event))
+			is.Equal(len(s.messages), tc.messages)
+			if tc.messages > 0 {
+				is.Equal(s.messages[0].subject(""), "DDNS: "+tc.event.Title())
+				is.Equal(s.messages[0].Body, tc.event.String())
+			}
 		})
 	}
 }
+
+func TestGroupNotify(t *testing.T) {
+	is := is.New(t)
+
+	failing := &fakeSender{err: errors.New("unavailable")}
+	working := &fakeSender{}
+	all := map[EventType]bool{EventStopped: true}
+
+	g := Group{
+		&notifier{name: "failing", events: all, sender: failing},
+		&notifier{name: "working", events: all, sender: working},
+	}
+
+	err := g.Notify(context.Background(), Event{Type: EventStopped})
+	is.True(err != nil)
+	is.Equal(err.Error(), "failing: unavailable")
+	is.Equal(len(failing.messages), 1)
+	is.Equal(len(working.messages), 1) // failure of one notifier doesn't stop the others
+}
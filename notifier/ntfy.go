@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ntfyPriorities are default priorities of the messages by the severity of the event.
+var ntfyPriorities = map[severity]int{
+	severityInfo:    3,
+	severityOK:      3,
+	severityWarning: 4,
+	severityError:   4,
+}
+
+// ntfyNotifier publishes messages to a ntfy topic (https://ntfy.sh).
+type ntfyNotifier struct {
+	// URL is an URL of the topic, e.g. https://ntfy.sh/mytopic.
+	URL string
+	// Priority is from 1 (min) to 5 (max),
+	// by default, it depends on the severity of the event.
+	Priority *int
+	Tags     []string
+	// Token or User and Password are used for authentication, if set.
+	Token    string
+	User     string
+	Password string
+
+	server string
+	topic  string
+	c      *http.Client
+}
+
+type ntfyPayload struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+func newNtfyNotifier(cfg interface{}) (*ntfyNotifier, error) {
+	var n ntfyNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(n.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	n.topic = path.Base(u.Path)
+	if n.topic == "/" || n.topic == "." {
+		return nil, errors.New("url has no topic")
+	}
+	u.Path = path.Dir(u.Path)
+	n.server = strings.TrimSuffix(u.String(), "/")
+
+	if n.Priority != nil && (*n.Priority < 1 || *n.Priority > 5) {
+		return nil, fmt.Errorf("priority should be from 1 to 5, got %d", *n.Priority)
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *ntfyNotifier) send(ctx context.Context, m message) error {
+	p := ntfyPayload{
+		Topic:    n.topic,
+		Title:    m.subject(""),
+		Message:  m.Body,
+		Priority: ntfyPriorities[m.Event.severity()],
+		Tags:     n.Tags,
+	}
+
+	if n.Priority != nil {
+		p.Priority = *n.Priority
+	}
+
+	if changes := m.Event.changes(); len(changes) > 0 {
+		p.Message += "\n\n" + recordsTable(changes)
+	}
+
+	headers := make(map[string]string)
+	switch {
+	case n.Token != "":
+		headers["Authorization"] = "Bearer " + n.Token
+	case n.User != "":
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(n.User+":"+n.Password))
+	}
+
+	return requestJSON(ctx, n.c, http.MethodPost, n.server+"/", headers, p)
+}
@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestNtfyNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname  string
+		cfg    interface{}
+		server string
+		topic  string
+		isErr  bool
+	}{
+		{"ok", map[string]interface{}{"url": "https://ntfy.sh/ddns"}, "https://ntfy.sh", "ddns", false},
+		{"ok self-hosted", map[string]interface{}{"url": "https://example.com/ntfy/ddns/", "priority": 5}, "https://example.com/ntfy", "ddns", false},
+		{"invalid config", "something unexpected", "", "", true},
+		{"invalid url", map[string]interface{}{"url": "oh no"}, "", "", true},
+		{"no topic", map[string]interface{}{"url": "https://ntfy.sh/"}, "", "", true},
+		{"invalid priority", map[string]interface{}{"url": "https://ntfy.sh/ddns", "priority": 6}, "", "", true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			n, err := newNtfyNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+			is.Equal(n.server, tc.server)
+			is.Equal(n.topic, tc.topic)
+		})
+	}
+}
+
+func TestNtfyNotifierSend(t *testing.T) {
+	tcases := []struct {
+		tname         string
+		cfg           map[string]interface{}
+		event         Event
+		statusCode    int
+		priority      int
+		authorization string
+		message       string
+		isErr         bool
+	}{
+		{
+			tname:      "ok default priority",
+			cfg:        map[string]interface{}{"tags": []string{"globe_with_meridians"}},
+			event:      Event{Type: EventSyncFailed, Error: "api is down"},
+			statusCode: http.StatusOK,
+			priority:   4,
+			message:    "failed to sync dns records: api is down",
+		},
+		{
+			tname:         "ok token",
+			cfg:           map[string]interface{}{"priority": 2, "token": "tk_secret"},
+			event:         Event{Type: EventRecordUpdated, Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}},
+			statusCode:    http.StatusOK,
+			priority:      2,
+			authorization: "Bearer tk_secret",
+			message: `record A www of the domain example.com has been created with "10.0.0.2"` + "\n\n" +
+				"DOMAIN       RECORD  OLD  NEW\n" +
+				"example.com  A www   -    10.0.0.2",
+		},
+		{
+			tname:         "ok basic auth",
+			cfg:           map[string]interface{}{"user": "ddns", "password": "secret"},
+			event:         Event{Type: EventStarted, NewIP: "10.0.0.1"},
+			statusCode:    http.StatusOK,
+			priority:      3,
+			authorization: "Basic ZGRuczpzZWNyZXQ=",
+			message:       "ddns has started, current ip is 10.0.0.1",
+		},
+		{
+			tname:      "fail status",
+			cfg:        map[string]interface{}{},
+			event:      Event{Type: EventStarted},
+			statusCode: http.StatusTooManyRequests,
+			isErr:      true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			tc.cfg["url"] = url + "/ddns"
+			n, err := newNtfyNotifier(tc.cfg)
+			is.NoErr(err)
+
+			err = n.send(context.Background(), message{Event: tc.event, Body: tc.event.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			reqs := requests()
+			is.Equal(len(reqs), 1)
+			is.Equal(reqs[0].URL, "/")
+			is.Equal(reqs[0].Header.Get("Authorization"), tc.authorization)
+
+			var p ntfyPayload
+			is.NoErr(json.Unmarshal(reqs[0].Body, &p))
+			is.Equal(p.Topic, "ddns")
+			is.Equal(p.Title, "DDNS: "+tc.event.Title())
+			is.Equal(p.Priority, tc.priority)
+			is.Equal(p.Message, tc.message)
+			if tags, ok := tc.cfg["tags"]; ok {
+				is.Equal(p.Tags, tags)
+			}
+		})
+	}
+}
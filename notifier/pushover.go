@@ -0,0 +1,127 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/skibish/ddns/misc"
+)
+
+// Parameters of the emergency priority, which repeats the message until it is acknowledged.
+const (
+	pushoverEmergency = 2
+	pushoverRetry     = "60"
+	pushoverExpire    = "3600"
+)
+
+// pushoverPriorities are default priorities of the messages by the severity of the event.
+var pushoverPriorities = map[severity]int{
+	severityInfo:    0,
+	severityOK:      0,
+	severityWarning: 0,
+	severityError:   1,
+}
+
+// pushoverNotifier sends messages using Pushover (https://pushover.net).
+type pushoverNotifier struct {
+	// User is a user or group key.
+	User string
+	// Token is an application token.
+	Token string
+	// Priority is from -2 (lowest) to 2 (emergency),
+	// by default, it depends on the severity of the event.
+	Priority *int
+	Sound    string
+	Device   string
+	host     string
+	c        *http.Client
+}
+
+type pushoverResponse struct {
+	Errors []string `json:"errors"`
+}
+
+func newPushoverNotifier(cfg interface{}) (*pushoverNotifier, error) {
+	var n pushoverNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if n.User == "" {
+		return nil, errors.New("user can't be empty")
+	}
+
+	if n.Token == "" {
+		return nil, errors.New("token can't be empty")
+	}
+
+	if n.Priority != nil && (*n.Priority < -2 || *n.Priority > pushoverEmergency) {
+		return nil, fmt.Errorf("priority should be from -2 to 2, got %d", *n.Priority)
+	}
+
+	n.host = "https://api.pushover.net"
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *pushoverNotifier) send(ctx context.Context, m message) error {
+	priority := pushoverPriorities[m.Event.severity()]
+	if n.Priority != nil {
+		priority = *n.Priority
+	}
+
+	body := m.Body
+	if changes := m.Event.changes(); len(changes) > 0 {
+		body += "\n\n" + recordsTable(changes)
+	}
+
+	form := url.Values{}
+	form.Set("token", n.Token)
+	form.Set("user", n.User)
+	form.Set("title", truncate(m.subject(""), 250))
+	form.Set("message", truncate(body, 1024))
+	form.Set("priority", strconv.Itoa(priority))
+	if priority == pushoverEmergency {
+		form.Set("retry", pushoverRetry)
+		form.Set("expire", pushoverExpire)
+	}
+	if n.Sound != "" {
+		form.Set("sound", n.Sound)
+	}
+	if n.Device != "" {
+		form.Set("device", n.Device)
+	}
+	if !m.Event.Time.IsZero() {
+		form.Set("timestamp", strconv.FormatInt(m.Event.Time.Unix(), 10))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.host+"/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create a request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := n.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to do a request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if !misc.Success(res.StatusCode) {
+		var r pushoverResponse
+		if err := json.NewDecoder(res.Body).Decode(&r); err == nil && len(r.Errors) > 0 {
+			return fmt.Errorf("status code is not in a success range: %d: %s", res.StatusCode, strings.Join(r.Errors, ", "))
+		}
+		return fmt.Errorf("status code is not in a success range: %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestPushoverNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]interface{}{"user": "ukey", "token": "atoken"}, false},
+		{"ok priority", map[string]interface{}{"user": "ukey", "token": "atoken", "priority": -2, "sound": "none"}, false},
+		{"invalid config", "something unexpected", true},
+		{"no user", map[string]interface{}{"token": "atoken"}, true},
+		{"no token", map[string]interface{}{"user": "ukey"}, true},
+		{"invalid priority", map[string]interface{}{"user": "ukey", "token": "atoken", "priority": 3}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newPushoverNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestPushoverNotifierSend(t *testing.T) {
+	tcases := []struct {
+		tname      string
+		cfg        map[string]interface{}
+		event      Event
+		statusCode int
+		response   string
+		expected   url.Values
+		errMsg     string
+	}{
+		{
+			tname:      "ok default priority",
+			cfg:        map[string]interface{}{"sound": "siren"},
+			event:      Event{Type: EventSyncFailed, Error: "api is down", Time: time.Unix(1700000000, 0)},
+			statusCode: http.StatusOK,
+			response:   `{"status":1}`,
+			expected: url.Values{
+				"token":     {"atoken"},
+				"user":      {"ukey"},
+				"title":     {"DDNS: sync failed"},
+				"message":   {"failed to sync dns records: api is down"},
+				"priority":  {"1"},
+				"sound":     {"siren"},
+				"timestamp": {"1700000000"},
+			},
+		},
+		{
+			tname:      "ok emergency",
+			cfg:        map[string]interface{}{"priority": 2, "device": "phone"},
+			event:      Event{Type: EventStarted, NewIP: "10.0.0.1"},
+			statusCode: http.StatusOK,
+			response:   `{"status":1}`,
+			expected: url.Values{
+				"token":    {"atoken"},
+				"user":     {"ukey"},
+				"title":    {"DDNS: started"},
+				"message":  {"ddns has started, current ip is 10.0.0.1"},
+				"priority": {"2"},
+				"retry":    {"60"},
+				"expire":   {"3600"},
+				"device":   {"phone"},
+			},
+		},
+		{
+			tname:      "fail status",
+			cfg:        map[string]interface{}{},
+			event:      Event{Type: EventStarted},
+			statusCode: http.StatusBadRequest,
+			response:   `{"user":"invalid","errors":["user identifier is invalid"],"status":0}`,
+			errMsg:     "status code is not in a success range: 400: user identifier is invalid",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			var form url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				is.Equal(r.URL.Path, "/1/messages.json")
+				_ = r.ParseForm()
+				form = r.PostForm
+				w.WriteHeader(tc.statusCode)
+				_, _ = w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			tc.cfg["user"] = "ukey"
+			tc.cfg["token"] = "atoken"
+			n, err := newPushoverNotifier(tc.cfg)
+			is.NoErr(err)
+			n.host = server.URL
+
+			err = n.send(context.Background(), message{Event: tc.event, Body: tc.event.String()})
+			if tc.errMsg != "" {
+				is.True(err != nil)
+				is.Equal(err.Error(), tc.errMsg)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(form, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults of the delivery queue.
+const (
+	defaultQueueSize  = 100
+	defaultRetries    = 5
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 5 * time.Minute
+	attemptTimeout    = 10 * time.Second
+)
+
+// queue delivers events to the notifier asynchronously,
+// so that slow or unavailable channels don't block the caller.
+// Failed deliveries are retried with exponential backoff.
+type queue struct {
+	notifier *notifier
+	size     int
+	retries  int
+	delay    time.Duration
+	// spool is a file where undelivered events are kept between restarts.
+	spool string
+	// limiter suppresses repeated events, if set.
+	limiter *limiter
+
+	mu sync.Mutex
+	// pending are undelivered events, the first one is being delivered.
+	pending []Event
+
+	wake    chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// newQueue starts delivery of the events to the notifier,
+// events left in the spool after the previous run are delivered first.
+func newQueue(n *notifier, size, retries int, delay time.Duration, spool string) *queue {
+	if size < 1 {
+		size = defaultQueueSize
+	}
+
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &queue{
+		notifier: n,
+		size:     size,
+		retries:  retries,
+		delay:    delay,
+		spool:    spool,
+		wake:     make(chan struct{}, 1),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	if spool != "" {
+		events, err := loadSpool(spool)
+		if err != nil {
+			log.Warnf("%s: failed to load undelivered notifications: %s", n.name, err)
+		}
+		q.pending = events
+	}
+
+	go q.run()
+
+	return q
+}
+
+// Notify queues the event, if the notifier is subscribed to it
+// and the event is not suppressed by the limiter.
+func (q *queue) Notify(_ context.Context, e Event) error {
+	if !q.notifier.subscribed(e.Type) {
+		return nil
+	}
+
+	if q.limiter != nil && !q.limiter.allow(e) {
+		log.Debugf("%s: notification about the event %s is suppressed", q.notifier.name, e.Type)
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case <-q.closing:
+		return fmt.Errorf("%s: notifier is closed", q.notifier.name)
+	default:
+	}
+
+	if len(q.pending) >= q.size {
+		return fmt.Errorf("%s: queue is full, the event %s is dropped", q.notifier.name, e.Type)
+	}
+
+	q.pending = append(q.pending, e)
+	q.save()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Close delivers queued events until ctx is done,
+// events which are not delivered are kept in the spool.
+func (q *queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	select {
+	case <-q.closing:
+	default:
+		close(q.closing)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		// the delivery in progress is canceled, but a stuck channel is not waited for,
+		// pending events are kept in the spool anyway
+		q.cancel()
+
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		return fmt.Errorf("%s: %d notifications are not delivered: %w", q.notifier.name, len(q.pending), ctx.Err())
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if n := len(q.pending); n > 0 {
+		return fmt.Errorf("%s: %d notifications are not delivered", q.notifier.name, n)
+	}
+
+	return nil
+}
+
+// run delivers events one by one until the queue is closed and flushed.
+func (q *queue) run() {
+	defer close(q.done)
+	defer q.cancel()
+
+	for {
+		e, ok := q.next()
+		if !ok {
+			return
+		}
+
+		if err := q.deliver(e); err != nil {
+			if q.ctx.Err() != nil {
+				// keep the event in the spool till the next run
+				log.Warnf("%s: failed to deliver the notification about the event %s before shutdown: %s", q.notifier.name, e.Type, err)
+				return
+			}
+			log.Warnf("%s: failed to deliver the notification about the event %s, dropped: %s", q.notifier.name, e.Type, err)
+		}
+
+		q.mu.Lock()
+		q.pending = q.pending[1:]
+		q.save()
+		q.mu.Unlock()
+	}
+}
+
+// next waits for the next event, it returns false, if the queue is closed and empty.
+func (q *queue) next() (Event, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			e := q.pending[0]
+			q.mu.Unlock()
+			return e, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.wake:
+		case <-q.closing:
+			q.mu.Lock()
+			empty := len(q.pending) == 0
+			q.mu.Unlock()
+			if empty {
+				return Event{}, false
+			}
+		}
+	}
+}
+
+// deliver sends the event, retrying with exponential backoff,
+// until retries are exhausted or the delivery is canceled by Close.
+func (q *queue) deliver(e Event) error {
+	delay := q.delay
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(q.ctx, attemptTimeout)
+		err := q.notifier.Notify(ctx, e)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if attempt > q.retries {
+			return err
+		}
+
+		select {
+		case <-q.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// save writes pending events to the spool.
+// Must be called with mu held.
+func (q *queue) save() {
+	if q.spool == "" {
+		return
+	}
+
+	if err := saveSpool(q.spool, q.pending); err != nil {
+		log.Warnf("%s: failed to save undelivered notifications: %s", q.notifier.name, err)
+	}
+}
+
+// loadSpool reads events from the spool file.
+// If the file does not exist, nothing is returned.
+func loadSpool(path string) ([]Event, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the spool: %w", err)
+	}
+
+	var events []Event
+	if err := json.Unmarshal(b, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode the spool: %w", err)
+	}
+
+	return events, nil
+}
+
+// saveSpool atomically replaces the spool file with the events.
+func saveSpool(path string, events []Event) error {
+	if events == nil {
+		events = []Event{}
+	}
+
+	b, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to encode the spool: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write the spool: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close the temporary file: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace the spool file: %w", err)
+	}
+
+	return nil
+}
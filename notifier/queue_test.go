@@ -0,0 +1,170 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// flakySender fails the first failures deliveries.
+type flakySender struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	messages []message
+	block    chan struct{}
+}
+
+func (s *flakySender) send(ctx context.Context, m message) error {
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.New("unavailable")
+	}
+
+	s.messages = append(s.messages, m)
+	return nil
+}
+
+func (s *flakySender) delivered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.messages)
+}
+
+func testNotifier(s sender) *notifier {
+	return &notifier{
+		name:   "test",
+		events: map[EventType]bool{EventIPChanged: true, EventSyncFailed: true},
+		sender: s,
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	tcases := []struct {
+		tname     string
+		failures  int
+		retries   int
+		attempts  int
+		delivered int
+	}{
+		{"ok", 0, 3, 1, 1},
+		{"ok after retries", 2, 3, 3, 1},
+		{"fail retries exhausted", 5, 2, 3, 0},
+		{"fail retries disabled", 1, 0, 1, 0},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			s := &flakySender{failures: tc.failures}
+			q := newQueue(testNotifier(s), 10, tc.retries, time.Millisecond, "")
+
+			is.NoErr(q.Notify(context.Background(), Event{Type: EventIPChanged}))
+			is.NoErr(q.Notify(context.Background(), Event{Type: EventStarted})) // not subscribed
+
+			is.NoErr(q.Close(context.Background()))
+			is.Equal(s.attempts, tc.attempts)
+			is.Equal(s.delivered(), tc.delivered)
+		})
+	}
+}
+
+func TestQueueDoesNotBlock(t *testing.T) {
+	is := is.New(t)
+
+	s := &flakySender{block: make(chan struct{})}
+	q := newQueue(testNotifier(s), 2, 1, time.Millisecond, "")
+
+	// the first event is being delivered, the second one waits
+	is.NoErr(q.Notify(context.Background(), Event{Type: EventIPChanged}))
+	is.NoErr(q.Notify(context.Background(), Event{Type: EventIPChanged}))
+
+	err := q.Notify(context.Background(), Event{Type: EventIPChanged})
+	is.True(err != nil) // queue is full
+
+	close(s.block)
+	is.NoErr(q.Close(context.Background()))
+	is.Equal(s.delivered(), 2)
+
+	err = q.Notify(context.Background(), Event{Type: EventIPChanged})
+	is.True(err != nil) // queue is closed
+}
+
+// stuckSender never returns, even if ctx is done.
+type stuckSender struct {
+	stuck chan struct{}
+}
+
+func (s stuckSender) send(context.Context, message) error {
+	<-s.stuck
+	return errors.New("unavailable")
+}
+
+func TestQueueCloseStuck(t *testing.T) {
+	is := is.New(t)
+
+	s := stuckSender{stuck: make(chan struct{})}
+	defer close(s.stuck)
+
+	q := newQueue(testNotifier(s), 10, 1, time.Millisecond, "")
+	is.NoErr(q.Notify(context.Background(), Event{Type: EventIPChanged}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := q.Close(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded)) // Close doesn't wait for the stuck channel
+}
+
+func TestQueueSpool(t *testing.T) {
+	is := is.New(t)
+
+	spool := filepath.Join(t.TempDir(), "spool.json")
+
+	// channel is down during shutdown, so the events are spooled
+	down := &flakySender{failures: 100}
+	q := newQueue(testNotifier(down), 10, 100, time.Hour, spool)
+	is.NoErr(q.Notify(context.Background(), Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}))
+	is.NoErr(q.Notify(context.Background(), Event{Type: EventSyncFailed, Error: "api is down"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := q.Close(ctx)
+	is.True(err != nil) // events are not delivered
+	is.Equal(down.delivered(), 0)
+
+	events, err := loadSpool(spool)
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+
+	// and are delivered after restart
+	up := &flakySender{}
+	q = newQueue(testNotifier(up), 10, 1, time.Millisecond, spool)
+	is.NoErr(q.Close(context.Background()))
+
+	is.Equal(up.delivered(), 2)
+	is.Equal(up.messages[0].Body, "ip has been changed from 10.0.0.1 to 10.0.0.2")
+	is.Equal(up.messages[1].Body, "failed to sync dns records: api is down")
+
+	events, err = loadSpool(spool)
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+}
@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Limits of Slack blocks.
+const (
+	// slackMaxText is the maximum length of the text of a section block.
+	slackMaxText = 3000
+	// slackMaxChanges is how many changed records are listed,
+	// the rest are summarized.
+	slackMaxChanges = 20
+)
+
+// slackNotifier posts messages to a Slack incoming webhook.
+type slackNotifier struct {
+	URL       string
+	Channel   string
+	Username  string
+	IconEmoji string `mapstructure:"icon_emoji"`
+	c         *http.Client
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+type slackPayload struct {
+	// Text is shown in notifications, where blocks are not supported.
+	Text      string       `json:"text"`
+	Channel   string       `json:"channel,omitempty"`
+	Username  string       `json:"username,omitempty"`
+	IconEmoji string       `json:"icon_emoji,omitempty"`
+	Blocks    []slackBlock `json:"blocks"`
+}
+
+func newSlackNotifier(cfg interface{}) (*slackNotifier, error) {
+	var n slackNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *slackNotifier) send(ctx context.Context, m message) error {
+	subject := m.subject("")
+
+	p := slackPayload{
+		Text:      subject + ": " + m.Body,
+		Channel:   n.Channel,
+		Username:  n.Username,
+		IconEmoji: n.IconEmoji,
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(subject, 150)}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncate(slackEscape(m.Body), slackMaxText)}},
+		},
+	}
+
+	if m.Event.OldIP != "" || m.Event.NewIP != "" {
+		p.Blocks = append(p.Blocks, slackBlock{
+			Type: "section",
+			Fields: []slackText{
+				{Type: "mrkdwn", Text: "*Old IP*\n" + slackEscape(orDash(m.Event.OldIP))},
+				{Type: "mrkdwn", Text: "*New IP*\n" + slackEscape(orDash(m.Event.NewIP))},
+			},
+		})
+	}
+
+	if changes := m.Event.changes(); len(changes) > 0 {
+		p.Blocks = append(p.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: slackRecords(changes)},
+		})
+	}
+
+	return postJSON(ctx, n.c, n.URL, p)
+}
+
+// slackRecords formats as many changed records as fit in a section block,
+// the rest are summarized.
+func slackRecords(changes []RecordChange) string {
+	for n := min(len(changes), slackMaxChanges); n > 0; n-- {
+		text := "```\n" + slackEscape(recordsTable(changes[:n])) + "\n```"
+		if n < len(changes) {
+			text += fmt.Sprintf("\nand %d more", len(changes)-n)
+		}
+
+		if utf8.RuneCountInString(text) <= slackMaxText {
+			return text
+		}
+	}
+
+	return fmt.Sprintf("%d changed records are too long to list", len(changes))
+}
+
+// slackEscape escapes control characters of Slack formatting.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncate cuts the string to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,147 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestSlackNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]string{"url": "https://hooks.slack.com/services/T/B/X", "channel": "#ops"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid url", map[string]string{"url": "oh no"}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newSlackNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestSlackRecords(t *testing.T) {
+	many := func(n int, data string) []RecordChange {
+		changes := make([]RecordChange, n)
+		for idx := range changes {
+			changes[idx] = RecordChange{Domain: "example.com", Record: do.Record{Type: "TXT", Name: fmt.Sprintf("r%d", idx), Data: data}}
+		}
+		return changes
+	}
+
+	tcases := []struct {
+		tname    string
+		changes  []RecordChange
+		expected string
+	}{
+		{"ok all", many(3, "10.0.0.2"), "r2 "},
+		{"ok limited count", many(100, "10.0.0.2"), "and 80 more"},
+		{"ok limited length", many(10, strings.Repeat("x", 1000)), "and 8 more"},
+		{"ok nothing fits", many(1, strings.Repeat("x", 4000)), "1 changed records are too long to list"},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			text := slackRecords(tc.changes)
+			is.True(len(text) <= slackMaxText)
+			is.True(strings.Contains(text, tc.expected))
+		})
+	}
+}
+
+func TestSlackNotifierSend(t *testing.T) {
+	tcases := []struct {
+		tname      string
+		statusCode int
+		event      Event
+		blocks     int
+		isErr      bool
+	}{
+		{
+			tname:      "ok ip changed",
+			statusCode: http.StatusOK,
+			event: Event{
+				Type:  EventIPChanged,
+				OldIP: "10.0.0.1",
+				NewIP: "10.0.0.2",
+				Records: []RecordChange{
+					{Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}, OldData: "10.0.0.1"},
+				},
+			},
+			blocks: 4,
+		},
+		{
+			tname:      "ok sync failed",
+			statusCode: http.StatusOK,
+			event:      Event{Type: EventSyncFailed, Error: "api <is> down"},
+			blocks:     2,
+		},
+		{
+			tname:      "fail status",
+			statusCode: http.StatusForbidden,
+			event:      Event{Type: EventSyncFailed, Error: "api is down"},
+			isErr:      true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			n, err := newSlackNotifier(map[string]string{"url": url, "channel": "#ops"})
+			is.NoErr(err)
+
+			err = n.send(context.Background(), message{Event: tc.event, Body: tc.event.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			reqs := requests()
+			is.Equal(len(reqs), 1)
+			is.Equal(reqs[0].Method, http.MethodPost)
+			is.Equal(reqs[0].Header.Get("Content-Type"), "application/json")
+
+			var p slackPayload
+			is.NoErr(json.Unmarshal(reqs[0].Body, &p))
+			is.Equal(p.Channel, "#ops")
+			is.Equal(p.Text, "DDNS: "+tc.event.Title()+": "+tc.event.String())
+			is.Equal(len(p.Blocks), tc.blocks)
+			is.Equal(p.Blocks[0].Text.Text, "DDNS: "+tc.event.Title())
+			is.True(!strings.Contains(p.Blocks[1].Text.Text, "<")) // formatting is escaped
+
+			if tc.blocks == 4 {
+				is.Equal(p.Blocks[2].Fields[0].Text, "*Old IP*\n10.0.0.1")
+				is.Equal(p.Blocks[2].Fields[1].Text, "*New IP*\n10.0.0.2")
+				is.True(strings.Contains(p.Blocks[3].Text.Text, "example.com  A www   10.0.0.1  10.0.0.2"))
+			}
+		})
+	}
+}
@@ -1,43 +1,55 @@
 package notifier
 
 import (
+	"context"
 	"fmt"
 	"net/mail"
 
 	"github.com/mitchellh/mapstructure"
-	"github.com/sirupsen/logrus"
 	"gopkg.in/gomail.v2"
 )
 
-// smtpHook is a structure for smtpHook configuration
-type smtpHook struct {
-	Host       string
-	Port       int
-	User       string
-	Password   string
-	From       string
-	To         string
-	Subject    string
-	senderFunc func() (gomail.SendCloser, error)
+// smtpNotifier is a structure for SMTP notifications configuration
+type smtpNotifier struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+	To       string
+	// Subject is the subject of messages without a subject template.
+	// If it is empty, the subject describes the event.
+	Subject string
+	// ContentType is the content type of the body, text/html by default.
+	ContentType string `mapstructure:"content_type"`
+	senderFunc  func() (gomail.SendCloser, error)
 }
 
-// newSMTPHook initializes SMTPConfig structure.
-func newSMTPHook(cfg interface{}) (*smtpHook, error) {
-	var hook smtpHook
-	if err := mapstructure.Decode(cfg, &hook); err != nil {
+// newSMTPNotifier initializes SMTPConfig structure.
+func newSMTPNotifier(cfg interface{}) (*smtpNotifier, error) {
+	var n smtpNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
 		return nil, fmt.Errorf("failed to decode configuration: %v", err)
 	}
 
-	if _, err := mail.ParseAddress(hook.From); err != nil {
+	if _, err := mail.ParseAddress(n.From); err != nil {
 		return nil, fmt.Errorf("failed to parse from address: %w", err)
 	}
 
-	if _, err := mail.ParseAddress(hook.To); err != nil {
+	if _, err := mail.ParseAddress(n.To); err != nil {
 		return nil, fmt.Errorf("failed to parse to address: %w", err)
 	}
 
-	hook.senderFunc = func() (gomail.SendCloser, error) {
-		d := gomail.NewDialer(hook.Host, hook.Port, hook.User, hook.Password)
+	switch n.ContentType {
+	case "":
+		n.ContentType = "text/html"
+	case "text/html", "text/plain":
+	default:
+		return nil, fmt.Errorf("content type %s is not supported", n.ContentType)
+	}
+
+	n.senderFunc = func() (gomail.SendCloser, error) {
+		d := gomail.NewDialer(n.Host, n.Port, n.User, n.Password)
 		s, err := d.Dial()
 		if err != nil {
 			return nil, err
@@ -46,30 +58,42 @@ func newSMTPHook(cfg interface{}) (*smtpHook, error) {
 		return s, nil
 	}
 
-	return &hook, nil
+	return &n, nil
+}
+
+// send sends the message, it gives up once ctx is done.
+// gomail limits only dialing, so a stuck server could block the exchange for good.
+func (n *smtpNotifier) send(ctx context.Context, m message) error {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", n.From)
+	msg.SetHeader("To", n.To)
+	msg.SetHeader("Subject", m.subject(n.Subject))
+	msg.SetBody(n.ContentType, m.Body)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- n.deliver(msg)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// Fire fires hook
-func (hook *smtpHook) Fire(entry *logrus.Entry) error {
-	m := gomail.NewMessage()
-	m.SetHeader("From", hook.From)
-	m.SetHeader("To", hook.To)
-	m.SetHeader("Subject", hook.Subject)
-	m.SetBody("text/html", entry.Message)
-	s, err := hook.senderFunc()
+// deliver dials the server and sends the message.
+func (n *smtpNotifier) deliver(msg *gomail.Message) error {
+	s, err := n.senderFunc()
 	if err != nil {
 		return err
 	}
 	defer s.Close()
 
-	if err := gomail.Send(s, m); err != nil {
+	if err := gomail.Send(s, msg); err != nil {
 		return err
 	}
 
 	return nil
 }
-
-// Levels return array of levels
-func (hook *smtpHook) Levels() []logrus.Level {
-	return allowedLevels()
-}
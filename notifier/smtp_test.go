@@ -1,22 +1,25 @@
 package notifier
 
 import (
+	"context"
 	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
-	"github.com/sirupsen/logrus"
 	"gopkg.in/gomail.v2"
 )
 
-func TestSMTPHookNew(t *testing.T) {
+func TestSMTPNotifierNew(t *testing.T) {
 	tcases := []struct {
 		tname string
 		cfg   interface{}
 		isErr bool
 	}{
 		{"ok", map[string]string{"from": "a@a.io", "to": "b@b.io"}, false},
+		{"ok plain text", map[string]string{"from": "a@a.io", "to": "b@b.io", "content_type": "text/plain"}, false},
+		{"unsupported content type", map[string]string{"from": "a@a.io", "to": "b@b.io", "content_type": "image/png"}, true},
 		{"invalid config", "something unexpected", true},
 		{"incorrect from", map[string]string{"from": "oh no"}, true},
 		{"incorrect to", map[string]string{"from": "oh@no.io", "to": "oh no"}, true},
@@ -26,7 +29,7 @@ func TestSMTPHookNew(t *testing.T) {
 		t.Run(tc.tname, func(t *testing.T) {
 			is := is.New(t)
 
-			_, err := newSMTPHook(tc.cfg)
+			_, err := newSMTPNotifier(tc.cfg)
 
 			if tc.isErr {
 				if err == nil {
@@ -51,10 +54,10 @@ func (m mockSender) Close() error {
 	return m.close()
 }
 
-func TestSMTPHookFire(t *testing.T) {
+func TestSMTPNotifierSend(t *testing.T) {
 	is := is.New(t)
 
-	hook, err := newSMTPHook(map[string]interface{}{
+	n, err := newSMTPNotifier(map[string]interface{}{
 		"host":     "smtp.email.server",
 		"port":     468,
 		"user":     "yo",
@@ -65,6 +68,9 @@ func TestSMTPHookFire(t *testing.T) {
 	})
 	is.NoErr(err)
 
+	stuck := make(chan struct{})
+	defer close(stuck)
+
 	tcases := []struct {
 		tname      string
 		senderFunc func() (gomail.SendCloser, error)
@@ -111,14 +117,24 @@ func TestSMTPHookFire(t *testing.T) {
 			},
 			isErr: true,
 		},
+		{
+			tname: "stuck server",
+			senderFunc: func() (gomail.SendCloser, error) {
+				<-stuck
+				return nil, errors.New("server is stuck")
+			},
+			isErr: true,
+		},
 	}
 
 	for _, tc := range tcases {
 		t.Run(tc.tname, func(t *testing.T) {
 			is := is.New(t)
-			hook.senderFunc = tc.senderFunc
-			entry := logrus.Entry{Message: "awesome message", Level: logrus.InfoLevel}
-			err = hook.Fire(&entry)
+			n.senderFunc = tc.senderFunc
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			err = n.send(ctx, message{Subject: "DDNS", Body: "awesome message"})
 			if tc.isErr {
 				if err == nil {
 					is.Fail() // should be error
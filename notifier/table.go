@@ -0,0 +1,48 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// recordsTable formats changed records as a plain text table.
+func recordsTable(changes []RecordChange) string {
+	var b strings.Builder
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tRECORD\tOLD\tNEW")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s %s\t%s\t%s\n", c.Domain, c.Record.Type, c.Record.Name, orDash(c.OldData), c.Record.Data)
+	}
+	w.Flush()
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// recordsMarkdownTable formats changed records as a markdown table.
+func recordsMarkdownTable(changes []RecordChange) string {
+	var b strings.Builder
+
+	b.WriteString("| Domain | Record | Old | New |\n")
+	b.WriteString("|---|---|---|---|\n")
+	for _, c := range changes {
+		fmt.Fprintf(&b, "| %s | %s %s | %s | %s |\n", markdownCell(c.Domain), c.Record.Type, markdownCell(c.Record.Name), markdownCell(orDash(c.OldData)), markdownCell(c.Record.Data))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// orDash returns "-" for empty values.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// markdownCell escapes pipes, which would break the markdown table.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
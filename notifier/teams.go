@@ -0,0 +1,126 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// teamsMaxChanges is how many changed records are listed,
+// so that the card fits into the size limit of the message.
+const teamsMaxChanges = 20
+
+// teamsColors are colors of the card title by the severity.
+var teamsColors = map[severity]string{
+	severityInfo:    "accent",
+	severityOK:      "good",
+	severityWarning: "warning",
+	severityError:   "attention",
+}
+
+// teamsNotifier posts Adaptive Cards to a Microsoft Teams
+// incoming webhook or a Power Automate workflow.
+type teamsNotifier struct {
+	URL string
+	c   *http.Client
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type teamsElement struct {
+	Type     string      `json:"type"`
+	Text     string      `json:"text,omitempty"`
+	Size     string      `json:"size,omitempty"`
+	Weight   string      `json:"weight,omitempty"`
+	Color    string      `json:"color,omitempty"`
+	IsSubtle bool        `json:"isSubtle,omitempty"`
+	Wrap     bool        `json:"wrap,omitempty"`
+	Facts    []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []teamsElement `json:"body"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+func newTeamsNotifier(cfg interface{}) (*teamsNotifier, error) {
+	var n teamsNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *teamsNotifier) send(ctx context.Context, m message) error {
+	body := []teamsElement{
+		{Type: "TextBlock", Text: m.subject(""), Size: "Medium", Weight: "Bolder", Color: teamsColors[m.Event.severity()], Wrap: true},
+		{Type: "TextBlock", Text: m.Body, Wrap: true},
+	}
+
+	if !m.Event.Time.IsZero() {
+		body = append(body, teamsElement{Type: "TextBlock", Text: m.Event.Time.UTC().Format(time.RFC3339), Size: "Small", IsSubtle: true})
+	}
+
+	if m.Event.OldIP != "" || m.Event.NewIP != "" {
+		body = append(body, teamsElement{Type: "FactSet", Facts: []teamsFact{
+			{Title: "Old IP", Value: orDash(m.Event.OldIP)},
+			{Title: "New IP", Value: orDash(m.Event.NewIP)},
+		}})
+	}
+
+	if changes := m.Event.changes(); len(changes) > 0 {
+		facts := make([]teamsFact, 0, len(changes))
+		for idx, c := range changes {
+			if idx == teamsMaxChanges {
+				facts = append(facts, teamsFact{Title: "…", Value: fmt.Sprintf("and %d more", len(changes)-idx)})
+				break
+			}
+			facts = append(facts, teamsFact{
+				Title: fmt.Sprintf("%s %s (%s)", c.Record.Type, c.Record.Name, c.Domain),
+				Value: orDash(c.OldData) + " → " + c.Record.Data,
+			})
+		}
+		body = append(body, teamsElement{Type: "FactSet", Facts: facts})
+	}
+
+	p := teamsPayload{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+			},
+		}},
+	}
+
+	return postJSON(ctx, n.c, n.URL, p)
+}
@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestTeamsNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]string{"url": "https://example.webhook.office.com/webhookb2/xxx"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid url", map[string]string{"url": "oh no"}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newTeamsNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestTeamsNotifierSend(t *testing.T) {
+	var many []RecordChange
+	for i := 0; i < teamsMaxChanges+5; i++ {
+		many = append(many, RecordChange{Domain: "example.com", Record: do.Record{Type: "A", Name: fmt.Sprintf("r%d", i), Data: "10.0.0.2"}})
+	}
+
+	tcases := []struct {
+		tname      string
+		statusCode int
+		event      Event
+		color      string
+		elements   int
+		facts      int
+		isErr      bool
+	}{
+		{
+			tname:      "ok ip changed",
+			statusCode: http.StatusAccepted,
+			event: Event{
+				Type:  EventIPChanged,
+				Time:  time.Unix(1700000000, 0),
+				OldIP: "10.0.0.1",
+				NewIP: "10.0.0.2",
+				Records: []RecordChange{
+					{Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}, OldData: "10.0.0.1"},
+				},
+			},
+			color:    "accent",
+			elements: 5,
+			facts:    1,
+		},
+		{
+			tname:      "ok sync failed",
+			statusCode: http.StatusOK,
+			event:      Event{Type: EventSyncFailed, Error: "api is down"},
+			color:      "attention",
+			elements:   2,
+		},
+		{
+			tname:      "ok many records",
+			statusCode: http.StatusOK,
+			event:      Event{Type: EventIPChanged, NewIP: "10.0.0.2", Records: many},
+			color:      "accent",
+			elements:   4,
+			facts:      teamsMaxChanges + 1,
+		},
+		{
+			tname:      "fail status",
+			statusCode: http.StatusBadRequest,
+			event:      Event{Type: EventStarted},
+			isErr:      true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			n, err := newTeamsNotifier(map[string]string{"url": url})
+			is.NoErr(err)
+
+			err = n.send(context.Background(), message{Event: tc.event, Body: tc.event.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			reqs := requests()
+			is.Equal(len(reqs), 1)
+
+			var p teamsPayload
+			is.NoErr(json.Unmarshal(reqs[0].Body, &p))
+			is.Equal(p.Type, "message")
+			is.Equal(len(p.Attachments), 1)
+			is.Equal(p.Attachments[0].ContentType, "application/vnd.microsoft.card.adaptive")
+
+			body := p.Attachments[0].Content.Body
+			is.Equal(len(body), tc.elements)
+			is.Equal(body[0].Text, "DDNS: "+tc.event.Title())
+			is.Equal(body[0].Color, tc.color)
+			is.Equal(body[1].Text, tc.event.String())
+			if tc.facts > 0 {
+				is.Equal(len(body[len(body)-1].Facts), tc.facts)
+			}
+		})
+	}
+}
@@ -1,70 +1,56 @@
 package notifier
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/mitchellh/mapstructure"
-	"github.com/sirupsen/logrus"
 	"github.com/skibish/ddns/misc"
 )
 
-// telegramHook is a structure for Telegram notifications configuration
-type telegramHook struct {
+// telegramNotifier is a structure for Telegram notifications configuration
+type telegramNotifier struct {
 	Token  string
 	ChatID string `mapstructure:"chat_id"`
 	host   string
 	c      *http.Client
 }
 
-func newTelegramHook(cfg interface{}) (*telegramHook, error) {
-	var hook telegramHook
-	if err := mapstructure.Decode(cfg, &hook); err != nil {
+func newTelegramNotifier(cfg interface{}) (*telegramNotifier, error) {
+	var n telegramNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
 		return nil, err
 	}
 
-	hook.host = "https://api.telegram.org"
-	hook.c = &http.Client{}
+	n.host = "https://api.telegram.org"
+	n.c = &http.Client{}
 
-	return &hook, nil
+	return &n, nil
 }
 
-func (hook *telegramHook) send(msg string) error {
+func (n *telegramNotifier) send(ctx context.Context, m message) error {
 	form := &url.Values{}
-	form.Add("chat_id", hook.ChatID)
-	form.Add("text", msg)
+	form.Add("chat_id", n.ChatID)
+	form.Add("text", m.Body)
 
-	url := fmt.Sprintf("%s/bot%s/sendMessage", hook.host, hook.Token)
-	req, err := http.NewRequest(http.MethodGet, url, strings.NewReader(form.Encode()))
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.host, n.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(form.Encode()))
 	if err != nil {
 		return fmt.Errorf("failed to create a request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	res, err := hook.c.Do(req)
+	res, err := n.c.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to do a request: %w", err)
 	}
 	defer res.Body.Close()
 
 	if !misc.Success(res.StatusCode) {
-		return fmt.Errorf("status code is not in a success range: %w", err)
+		return fmt.Errorf("status code is not in a success range: %d", res.StatusCode)
 	}
 
 	return nil
 }
-
-// Fire fires hook
-func (hook *telegramHook) Fire(entry *logrus.Entry) error {
-	if err := hook.send(entry.Message); err != nil {
-		return fmt.Errorf("failed to send a message to the chat: %w", err)
-	}
-
-	return nil
-}
-
-// Levels return array of levels
-func (hook *telegramHook) Levels() []logrus.Level {
-	return allowedLevels()
-}
@@ -1,17 +1,17 @@
 package notifier
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
 	"github.com/matryer/is"
-	"github.com/sirupsen/logrus"
 )
 
-func TestTelegramHookNew(t *testing.T) {
+func TestTelegramNotifierNew(t *testing.T) {
 	is := is.New(t)
 
-	if _, err := newTelegramHook("cfg"); err == nil {
+	if _, err := newTelegramNotifier("cfg"); err == nil {
 		is.Fail() // should be error, but got nothing
 	}
 
@@ -19,14 +19,14 @@ func TestTelegramHookNew(t *testing.T) {
 	m["chat_id"] = "123"
 	m["token"] = "tokenv"
 
-	hook, err := newTelegramHook(m)
+	n, err := newTelegramNotifier(m)
 
 	is.NoErr(err)
-	is.Equal(hook.ChatID, "123")
-	is.Equal(hook.Token, "tokenv")
+	is.Equal(n.ChatID, "123")
+	is.Equal(n.Token, "tokenv")
 }
 
-func TestTelegramHookFire(t *testing.T) {
+func TestTelegramNotifierSend(t *testing.T) {
 	tcases := []struct {
 		tname      string
 		statusCode int
@@ -44,19 +44,18 @@ func TestTelegramHookFire(t *testing.T) {
 			url, close := httpHelper(t, tc.tname, nil, tc.statusCode)
 			defer close()
 
-			hook, err := newTelegramHook(map[string]interface{}{
+			n, err := newTelegramNotifier(map[string]interface{}{
 				"chat_id": "someid",
 				"token":   "1234",
 			})
 			is.NoErr(err)
 
-			hook.host = url
+			n.host = url
 			if tc.tname == "fail do" {
-				hook.host = " "
+				n.host = " "
 			}
 
-			entry := logrus.Entry{Message: tc.tname, Level: logrus.InfoLevel}
-			err = hook.Fire(&entry)
+			err = n.send(context.Background(), message{Body: tc.tname})
 
 			if tc.isErr {
 				if err == nil {
@@ -0,0 +1,104 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// hostname is a name of the host, which is available in templates.
+var hostname = func() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	return name
+}
+
+// templateConfig is a configuration of templates of the event messages.
+type templateConfig struct {
+	Subject string
+	Body    string
+}
+
+// messageTemplate renders subject and body of the event message.
+// Nil templates are not rendered.
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// templateData is what is available in the templates.
+type templateData struct {
+	Event
+	Hostname string
+	// Message is the default message about the event.
+	Message string
+}
+
+// parseTemplates parses templates of the event messages.
+func parseTemplates(cfg map[string]templateConfig) (map[EventType]messageTemplate, error) {
+	res := make(map[EventType]messageTemplate, len(cfg))
+	for name, c := range cfg {
+		t, err := parseEventType(strings.ToLower(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse templates: %w", err)
+		}
+
+		var mt messageTemplate
+		if c.Subject != "" {
+			mt.subject, err = template.New("subject").Parse(c.Subject)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the subject template of the event %s: %w", t, err)
+			}
+		}
+
+		if c.Body != "" {
+			mt.body, err = template.New("body").Parse(c.Body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the body template of the event %s: %w", t, err)
+			}
+		}
+
+		res[t] = mt
+	}
+
+	return res, nil
+}
+
+// render returns the message about the event.
+// Subject is empty, if there is no subject template.
+func (mt messageTemplate) render(e Event) (message, error) {
+	m := message{Event: e, Body: e.String()}
+	data := templateData{Event: e, Hostname: hostname(), Message: m.Body}
+
+	if mt.subject != nil {
+		s, err := execute(mt.subject, data)
+		if err != nil {
+			return message{}, fmt.Errorf("failed to execute the subject template: %w", err)
+		}
+		m.Subject = s
+	}
+
+	if mt.body != nil {
+		b, err := execute(mt.body, data)
+		if err != nil {
+			return message{}, fmt.Errorf("failed to execute the body template: %w", err)
+		}
+		m.Body = b
+	}
+
+	return m, nil
+}
+
+func execute(t *template.Template, data templateData) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,137 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/do"
+)
+
+func TestNotifierTemplates(t *testing.T) {
+	defer func(h func() string) { hostname = h }(hostname)
+	hostname = func() string { return "router" }
+
+	tcases := []struct {
+		tname           string
+		templates       map[string]interface{}
+		event           Event
+		expectedSubject string
+		expectedBody    string
+		isErr           bool
+	}{
+		{
+			tname: "ok ip changed",
+			templates: map[string]interface{}{
+				"ip_changed": map[string]interface{}{
+					"subject": "{{.Hostname}}: new IP",
+					"body":    "{{.OldIP}} -> {{.NewIP}}",
+				},
+			},
+			event:           Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"},
+			expectedSubject: "router: new IP",
+			expectedBody:    "10.0.0.1 -> 10.0.0.2",
+		},
+		{
+			tname: "ok record updated",
+			templates: map[string]interface{}{
+				"RECORD_UPDATED": map[string]interface{}{
+					"body": "{{.Record.Type}} {{.Record.Name}}.{{.Domain}} = {{.Record.Data}} ({{.Message}})",
+				},
+			},
+			event:           Event{Type: EventRecordUpdated, Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}},
+			expectedSubject: "DDNS: record updated",
+			expectedBody:    `A www.example.com = 10.0.0.2 (record A www of the domain example.com has been created with "10.0.0.2")`,
+		},
+		{
+			tname: "ok ip changed records",
+			templates: map[string]interface{}{
+				"ip_changed": map[string]interface{}{
+					"body": "{{.NewIP}}:{{range .Records}} {{.Record.Name}}.{{.Domain}}{{end}}",
+				},
+			},
+			event: Event{Type: EventIPChanged, NewIP: "10.0.0.2", Records: []RecordChange{
+				{Domain: "example.com", Record: do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}},
+				{Domain: "example.org", Record: do.Record{Type: "A", Name: "vpn", Data: "10.0.0.2"}},
+			}},
+			expectedSubject: "DDNS: IP changed",
+			expectedBody:    "10.0.0.2: www.example.com vpn.example.org",
+		},
+		{
+			tname: "ok default for other events",
+			templates: map[string]interface{}{
+				"ip_changed": map[string]interface{}{"body": "{{.NewIP}}"},
+			},
+			event:           Event{Type: EventSyncFailed, Error: "api is down"},
+			expectedSubject: "DDNS: sync failed",
+			expectedBody:    "failed to sync dns records: api is down",
+		},
+		{
+			tname: "fail execute",
+			templates: map[string]interface{}{
+				"sync_failed": map[string]interface{}{"body": "{{.Unknown}}"},
+			},
+			event: Event{Type: EventSyncFailed, Error: "api is down"},
+			isErr: true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			n, err := newTestNotifier(map[string]interface{}{
+				"type":      "telegram",
+				"chat_id":   "someid",
+				"token":     "1234",
+				"events":    []string{string(tc.event.Type)},
+				"templates": tc.templates,
+			})
+			is.NoErr(err)
+
+			s := &fakeSender{}
+			n.sender = s
+
+			err = n.Notify(context.Background(), tc.event)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+
+			is.NoErr(err)
+			is.Equal(len(s.messages), 1)
+			is.Equal(s.messages[0].subject(""), tc.expectedSubject)
+			is.Equal(s.messages[0].Body, tc.expectedBody)
+		})
+	}
+}
+
+func TestParseTemplates(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   map[string]templateConfig
+		isErr bool
+	}{
+		{"ok", map[string]templateConfig{"started": {Subject: "{{.Hostname}}", Body: "{{.NewIP}}"}}, false},
+		{"fail unknown event", map[string]templateConfig{"restarted": {Body: "{{.NewIP}}"}}, true},
+		{"fail subject", map[string]templateConfig{"started": {Subject: "{{.Hostname"}}, true},
+		{"fail body", map[string]templateConfig{"started": {Body: "{{if}}"}}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := parseTemplates(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
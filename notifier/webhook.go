@@ -0,0 +1,169 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// defaultSignatureHeader is a header with the HMAC signature of the body.
+const defaultSignatureHeader = "X-DDNS-Signature"
+
+// webhookMethods are the allowed methods of the webhook.
+var webhookMethods = map[string]bool{
+	http.MethodPost:  true,
+	http.MethodPut:   true,
+	http.MethodPatch: true,
+}
+
+// webhookFuncs are functions available in the webhook templates.
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhookNotifier sends events to an arbitrary HTTP endpoint.
+type webhookNotifier struct {
+	URL string
+	// Method is POST by default.
+	Method string
+	// Headers are templates of the request headers.
+	Headers map[string]string
+	// Body is a template of the request body,
+	// by default, the event is sent as JSON.
+	Body        string
+	ContentType string `mapstructure:"content_type"`
+	// Secret is a key of the HMAC-SHA256 signature of the body,
+	// the signature is not sent, if it is empty.
+	Secret          string
+	SignatureHeader string `mapstructure:"signature_header"`
+
+	headers map[string]*template.Template
+	body    *template.Template
+	c       *http.Client
+}
+
+// webhookData is what is available in the webhook templates.
+type webhookData struct {
+	Event
+	Hostname string
+	Subject  string
+	Message  string
+}
+
+// webhookPayload is the default body of the webhook.
+type webhookPayload struct {
+	Event    Event  `json:"event"`
+	Hostname string `json:"hostname"`
+	Subject  string `json:"subject"`
+	Message  string `json:"message"`
+}
+
+func newWebhookNotifier(cfg interface{}) (*webhookNotifier, error) {
+	var n webhookNotifier
+	if err := mapstructure.Decode(cfg, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	if !isValidURL(n.URL) {
+		return nil, errors.New("url is not a valid url")
+	}
+
+	n.Method = strings.ToUpper(n.Method)
+	if n.Method == "" {
+		n.Method = http.MethodPost
+	}
+	if !webhookMethods[n.Method] {
+		return nil, fmt.Errorf("method %s is not supported", n.Method)
+	}
+
+	if n.ContentType == "" {
+		n.ContentType = "application/json"
+	}
+
+	if n.SignatureHeader == "" {
+		n.SignatureHeader = defaultSignatureHeader
+	}
+
+	n.headers = make(map[string]*template.Template, len(n.Headers))
+	for k, v := range n.Headers {
+		t, err := template.New(k).Funcs(webhookFuncs).Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the template of the header %s: %w", k, err)
+		}
+		n.headers[k] = t
+	}
+
+	if n.Body != "" {
+		t, err := template.New("body").Funcs(webhookFuncs).Parse(n.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the body template: %w", err)
+		}
+		n.body = t
+	}
+
+	n.c = &http.Client{}
+
+	return &n, nil
+}
+
+func (n *webhookNotifier) send(ctx context.Context, m message) error {
+	data := webhookData{Event: m.Event, Hostname: hostname(), Subject: m.subject(""), Message: m.Body}
+
+	body, err := n.render(data)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{"Content-Type": n.ContentType}
+	for k, t := range n.headers {
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return fmt.Errorf("failed to execute the template of the header %s: %w", k, err)
+		}
+		headers[k] = b.String()
+	}
+
+	if n.Secret != "" {
+		headers[n.SignatureHeader] = "sha256=" + sign(n.Secret, body)
+	}
+
+	return doRequest(ctx, n.c, n.Method, n.URL, headers, body)
+}
+
+// render returns the body of the request.
+func (n *webhookNotifier) render(data webhookData) ([]byte, error) {
+	if n.body == nil {
+		b, err := json.Marshal(webhookPayload{Event: data.Event, Hostname: data.Hostname, Subject: data.Subject, Message: data.Message})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the payload: %w", err)
+		}
+		return b, nil
+	}
+
+	var b strings.Builder
+	if err := n.body.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to execute the body template: %w", err)
+	}
+
+	return []byte(b.String()), nil
+}
+
+// sign returns hex encoded HMAC-SHA256 of the body.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
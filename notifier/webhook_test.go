@@ -0,0 +1,139 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestWebhookNotifierNew(t *testing.T) {
+	tcases := []struct {
+		tname string
+		cfg   interface{}
+		isErr bool
+	}{
+		{"ok", map[string]interface{}{"url": "https://example.com/hook"}, false},
+		{"ok put", map[string]interface{}{"url": "https://example.com/hook", "method": "put", "body": "{{ .Message }}"}, false},
+		{"invalid config", "something unexpected", true},
+		{"invalid url", map[string]interface{}{"url": "oh no"}, true},
+		{"invalid method", map[string]interface{}{"url": "https://example.com/hook", "method": "DELETE"}, true},
+		{"invalid body", map[string]interface{}{"url": "https://example.com/hook", "body": "{{ .Message"}, true},
+		{"invalid header", map[string]interface{}{"url": "https://example.com/hook", "headers": map[string]string{"X-Event": "{{ .Type"}}, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newWebhookNotifier(tc.cfg)
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestWebhookNotifierSend(t *testing.T) {
+	defer func(h func() string) { hostname = h }(hostname)
+	hostname = func() string { return "router" }
+
+	event := Event{Type: EventIPChanged, OldIP: "10.0.0.1", NewIP: "10.0.0.2"}
+
+	tcases := []struct {
+		tname      string
+		cfg        map[string]interface{}
+		statusCode int
+		method     string
+		headers    map[string]string
+		body       string
+		isErr      bool
+	}{
+		{
+			tname:      "ok default",
+			cfg:        map[string]interface{}{},
+			statusCode: http.StatusOK,
+			method:     http.MethodPost,
+			headers:    map[string]string{"Content-Type": "application/json"},
+		},
+		{
+			tname: "ok templates",
+			cfg: map[string]interface{}{
+				"method":       "PUT",
+				"content_type": "text/plain",
+				"headers":      map[string]string{"X-Event": "{{ .Type }}", "Authorization": "Bearer secret"},
+				"body":         `{{ .Hostname }}: {{ .Message }} {{ json .NewIP }}`,
+			},
+			statusCode: http.StatusNoContent,
+			method:     http.MethodPut,
+			headers:    map[string]string{"Content-Type": "text/plain", "X-Event": "ip_changed", "Authorization": "Bearer secret"},
+			body:       `router: ip has been changed from 10.0.0.1 to 10.0.0.2 "10.0.0.2"`,
+		},
+		{
+			tname: "ok signature",
+			cfg: map[string]interface{}{
+				"body":             "hello",
+				"secret":           "key",
+				"signature_header": "X-Hub-Signature-256",
+			},
+			statusCode: http.StatusOK,
+			method:     http.MethodPost,
+			headers:    map[string]string{"X-Hub-Signature-256": "sha256=9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"},
+			body:       "hello",
+		},
+		{
+			tname:      "fail status",
+			cfg:        map[string]interface{}{},
+			statusCode: http.StatusInternalServerError,
+			isErr:      true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			url, requests, close := recordHelper(t, tc.statusCode)
+			defer close()
+
+			tc.cfg["url"] = url
+			n, err := newWebhookNotifier(tc.cfg)
+			is.NoErr(err)
+
+			err = n.send(context.Background(), message{Event: event, Body: event.String()})
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+			is.NoErr(err)
+
+			reqs := requests()
+			is.Equal(len(reqs), 1)
+			is.Equal(reqs[0].Method, tc.method)
+			for k, v := range tc.headers {
+				is.Equal(reqs[0].Header.Get(k), v)
+			}
+
+			if tc.body != "" {
+				is.Equal(string(reqs[0].Body), tc.body)
+				return
+			}
+
+			var p webhookPayload
+			is.NoErr(json.Unmarshal(reqs[0].Body, &p))
+			is.Equal(p.Event.Type, EventIPChanged)
+			is.Equal(p.Event.NewIP, "10.0.0.2")
+			is.Equal(p.Hostname, "router")
+			is.Equal(p.Subject, "DDNS: IP changed")
+			is.Equal(p.Message, "ip has been changed from 10.0.0.1 to 10.0.0.2")
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Record is a published DNS record.
+type Record struct {
+	ID   uint64 `json:"id"`
+	Data string `json:"data"`
+	// TTL is the configured TTL, zero means that it is not configured.
+	TTL uint64 `json:"ttl,omitempty"`
+}
+
+// State is what is known about published DNS records,
+// it is persisted between restarts.
+type State struct {
+	IP       string                       `json:"ip"`
+	Records  map[string]map[string]Record `json:"records"`
+	LastSync time.Time                    `json:"last_sync"`
+}
+
+// Key return key of the record in the domain records.
+func Key(recordType, name string) string {
+	return recordType + "/" + name
+}
+
+// New return an empty state.
+func New() *State {
+	return &State{Records: make(map[string]map[string]Record)}
+}
+
+// Load reads state from the file.
+// If the file does not exist, an empty state is returned.
+func Load(path string) (*State, error) {
+	s := New()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read the state file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to decode the state file: %w", err)
+	}
+
+	if s.Records == nil {
+		s.Records = make(map[string]map[string]Record)
+	}
+
+	return s, nil
+}
+
+// Save writes state to the file atomically:
+// it is written to a temporary file first, which then replaces the original one.
+func (s *State) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the state: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write the state: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to flush the state: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close the temporary file: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace the state file: %w", err)
+	}
+
+	return nil
+}
+
+// Get return the record of the domain.
+func (s *State) Get(domain, recordType, name string) (Record, bool) {
+	r, ok := s.Records[domain][Key(recordType, name)]
+	return r, ok
+}
+
+// Set stores the record of the domain.
+func (s *State) Set(domain, recordType, name string, r Record) {
+	if s.Records[domain] == nil {
+		s.Records[domain] = make(map[string]Record)
+	}
+
+	s.Records[domain][Key(recordType, name)] = r
+}
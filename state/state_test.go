@@ -0,0 +1,79 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestLoadNotExist(t *testing.T) {
+	is := is.New(t)
+
+	s, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	is.NoErr(err)
+	is.Equal(s.IP, "")
+	is.Equal(len(s.Records), 0)
+
+	_, ok := s.Get("example.com", "A", "www")
+	is.True(!ok)
+}
+
+func TestLoadFail(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	is.NoErr(os.WriteFile(path, []byte("is not json"), 0600))
+
+	if _, err := Load(path); err == nil {
+		is.Fail() // should be error
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	synced := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s, err := Load(path)
+	is.NoErr(err)
+
+	s.IP = "45.45.45.45"
+	s.LastSync = synced
+	s.Set("example.com", "A", "www", Record{ID: 123, Data: "45.45.45.45"})
+	is.NoErr(s.Save(path))
+
+	// overwrite
+	s.Set("example.com", "TXT", "www", Record{ID: 124, Data: "hello"})
+	is.NoErr(s.Save(path))
+
+	loaded, err := Load(path)
+	is.NoErr(err)
+	is.Equal(loaded.IP, "45.45.45.45")
+	is.True(loaded.LastSync.Equal(synced))
+
+	r, ok := loaded.Get("example.com", "A", "www")
+	is.True(ok)
+	is.Equal(r, Record{ID: 123, Data: "45.45.45.45"})
+
+	r, ok = loaded.Get("example.com", "TXT", "www")
+	is.True(ok)
+	is.Equal(r.ID, uint64(124))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	is.NoErr(err)
+	is.Equal(len(entries), 1)
+}
+
+func TestSaveFail(t *testing.T) {
+	is := is.New(t)
+
+	s := &State{}
+	if err := s.Save(filepath.Join(t.TempDir(), "missing", "state.json")); err == nil {
+		is.Fail() // should be error, directory does not exist
+	}
+}
@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skibish/ddns/do"
+)
+
+// Action is what has been done with a record during sync.
+type Action string
+
+// Actions of the sync.
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+	// ActionSkipped means that the API has not been called,
+	// because the nameservers already serve the desired data.
+	ActionSkipped Action = "skipped"
+	ActionFailed  Action = "failed"
+)
+
+// actions is the order in which actions are reported.
+var actions = []Action{ActionCreated, ActionUpdated, ActionUnchanged, ActionSkipped, ActionFailed}
+
+// RecordResult is a result of the sync of a single record.
+type RecordResult struct {
+	Domain string
+	// Record is the configured record with the desired data.
+	Record   do.Record
+	Action   Action
+	OldData  string
+	NewData  string
+	Duration time.Duration
+	Err      error
+}
+
+// Changed reports whether the record has been created or updated.
+func (r RecordResult) Changed() bool {
+	return r.Action == ActionCreated || r.Action == ActionUpdated
+}
+
+func (r RecordResult) String() string {
+	name := fmt.Sprintf("%s %s of the domain %s", r.Record.Type, r.Record.Name, r.Domain)
+
+	switch r.Action {
+	case ActionCreated:
+		return fmt.Sprintf("%s (created with %q)", name, r.NewData)
+	case ActionUpdated:
+		return fmt.Sprintf("%s (%q changed to %q)", name, r.OldData, r.NewData)
+	case ActionUnchanged:
+		return fmt.Sprintf("%s (%q is up to date)", name, r.NewData)
+	case ActionSkipped:
+		return fmt.Sprintf("%s (%q is already published)", name, r.NewData)
+	default:
+		return fmt.Sprintf("%s (failed: %s)", name, r.Err)
+	}
+}
+
+// SyncReport is a result of the sync of all records.
+type SyncReport struct {
+	IP       string
+	Started  time.Time
+	Duration time.Duration
+	Records  []RecordResult
+}
+
+// Changed returns records which have been created or updated.
+func (r SyncReport) Changed() []RecordResult {
+	var res []RecordResult
+	for _, rr := range r.Records {
+		if rr.Changed() {
+			res = append(res, rr)
+		}
+	}
+
+	return res
+}
+
+// Count returns the number of records with the action.
+func (r SyncReport) Count(a Action) int {
+	var n int
+	for _, rr := range r.Records {
+		if rr.Action == a {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Err returns errors of all failed records or nil.
+// An error shared by several records (e.g. failed listing of the domain) is returned once.
+func (r SyncReport) Err() error {
+	var errs []error
+	for _, rr := range r.Records {
+		if rr.Err == nil || (len(errs) > 0 && errs[len(errs)-1] == rr.Err) {
+			continue
+		}
+		errs = append(errs, rr.Err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r SyncReport) String() string {
+	parts := make([]string, 0, len(actions))
+	for _, a := range actions {
+		if n := r.Count(a); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, a))
+		}
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, "no records")
+	}
+
+	return fmt.Sprintf("%s in %s", strings.Join(parts, ", "), r.Duration.Round(time.Millisecond))
+}
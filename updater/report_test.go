@@ -0,0 +1,86 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/skibish/ddns/conf"
+	"github.com/skibish/ddns/do"
+)
+
+func TestSyncReport(t *testing.T) {
+	is := is.New(t)
+
+	dm := &DomainsServiceMock{
+		ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+			if s == "down.com" {
+				return nil, errors.New("api is down")
+			}
+			return []do.Record{
+				{ID: 1, Type: "A", Name: "same", Data: "10.0.5.1"},
+				{ID: 2, Type: "A", Name: "old", Data: "1.1.1.1"},
+				{ID: 3, Type: "A", Name: "broken", Data: "1.1.1.1"},
+			}, nil
+		},
+		CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return nil
+		},
+		UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			if record.Name == "broken" {
+				return errors.New("rejected")
+			}
+			return nil
+		},
+	}
+
+	u, err := New(&conf.Configuration{
+		Domains: map[string][]do.Record{
+			"example.com": {
+				{Type: "A", Name: "same"},
+				{Type: "A", Name: "old"},
+				{Type: "A", Name: "new"},
+				{Type: "A", Name: "broken"},
+				{Type: "TXT", Name: "bad", Data: "{{.IP"},
+			},
+			"down.com": {{Type: "A", Name: "a"}, {Type: "A", Name: "b"}},
+		},
+		SyncWorkers:    2,
+		CheckPeriod:    1 * time.Hour,
+		RequestTimeout: 5 * time.Second,
+	})
+	is.NoErr(err)
+	u.do = dm
+	u.ip = "10.0.5.1"
+
+	report := u.sync(context.Background())
+
+	is.Equal(report.IP, "10.0.5.1")
+	is.Equal(len(report.Records), 7)
+	is.Equal(report.Count(ActionUnchanged), 1)
+	is.Equal(report.Count(ActionUpdated), 1)
+	is.Equal(report.Count(ActionCreated), 1)
+	is.Equal(report.Count(ActionFailed), 4)
+	is.Equal(len(report.Changed()), 2)
+	is.Equal(report.String()[:43], "1 created, 1 updated, 1 unchanged, 4 failed")
+
+	byName := make(map[string]RecordResult)
+	for _, r := range report.Records {
+		byName[r.Record.Name] = r
+	}
+
+	is.Equal(byName["old"].OldData, "1.1.1.1")
+	is.Equal(byName["old"].NewData, "10.0.5.1")
+	is.Equal(byName["old"].String(), `A old of the domain example.com ("1.1.1.1" changed to "10.0.5.1")`)
+	is.Equal(byName["new"].String(), `A new of the domain example.com (created with "10.0.5.1")`)
+	is.True(byName["broken"].Err != nil)
+	is.True(byName["bad"].Err != nil)
+	is.Equal(byName["a"].Err, byName["b"].Err)
+
+	// the listing error of down.com is reported once
+	err = report.Err()
+	is.True(err != nil)
+	is.Equal(len(err.(interface{ Unwrap() []error }).Unwrap()), 3)
+}
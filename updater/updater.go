@@ -3,43 +3,146 @@ package updater
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
+	"net/netip"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/skibish/ddns/conf"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/skibish/ddns/dnscheck"
 	"github.com/skibish/ddns/do"
 	"github.com/skibish/ddns/ipprovider"
+	"github.com/skibish/ddns/mqtt"
+	"github.com/skibish/ddns/netwatch"
+	"github.com/skibish/ddns/notifier"
+	"github.com/skibish/ddns/state"
 )
 
 //go:generate moq -out do_moq_test.go -pkg updater ../do DomainsService
 //go:generate moq -out ipprovider_moq_test.go -pkg updater ../ipprovider Provider
 
+// defaultSettleDelay is for how long network changes should stop,
+// before IP is checked.
+const defaultSettleDelay = 2 * time.Second
+
+// defaultMaxSettleDelay is for how long IP is not checked at most,
+// if network changes don't stop.
+const defaultMaxSettleDelay = 10 * time.Second
+
+// defaultHealthPeriod is how often health of IP providers is logged.
+const defaultHealthPeriod = time.Hour
+
+// flushTimeout is for how long pending notifications are delivered on shutdown.
+const flushTimeout = 5 * time.Second
+
 // Updater is responsible for DNS records updates.
 type Updater struct {
 	ip         string
 	ticker     *time.Ticker
 	do         do.DomainsService
 	ipprovider ipprovider.Provider
-	config     *conf.Configuration
-	shutdown   chan bool
+	watcher    netwatch.Watcher
+	state      *state.State
+	checker    dnscheck.Checker
+	notifier   notifier.Notifier
+	publisher  mqtt.Publisher
+	// pending is set, if the last sync failed and should be retried.
+	pending bool
+	// verifying tracks verifications of the propagation running in the background.
+	verifying   sync.WaitGroup
+	mu          sync.Mutex // guards state
+	settleDelay time.Duration
+	// maxSettleDelay limits the delay since the first change of a burst.
+	maxSettleDelay time.Duration
+	// healthPeriod is how often health of IP providers is logged.
+	healthPeriod time.Duration
+	config       *conf.Configuration
+	shutdown     chan bool
 }
 
 // New return new Updater.
-func New(cfg *conf.Configuration) *Updater {
-	return &Updater{
-		ticker:     time.NewTicker(cfg.CheckPeriod),
-		do:         do.New(cfg.Token, cfg.RequestTimeout),
-		ipprovider: ipprovider.New(cfg.IPv6, cfg.RequestTimeout),
-		shutdown:   make(chan bool),
-		config:     cfg,
+func New(cfg *conf.Configuration) (*Updater, error) {
+	u := &Updater{
+		ticker:         time.NewTicker(cfg.CheckPeriod),
+		do:             do.New(cfg.Token, cfg.RequestTimeout),
+		watcher:        netwatch.New(),
+		settleDelay:    defaultSettleDelay,
+		maxSettleDelay: defaultMaxSettleDelay,
+		healthPeriod:   defaultHealthPeriod,
+		shutdown:       make(chan bool),
+		config:         cfg,
 	}
+
+	ipp, err := ipprovider.New(ipprovider.Config{
+		IPv6:             cfg.IPv6,
+		Timeout:          cfg.RequestTimeout,
+		Providers:        cfg.Providers,
+		Strategy:         cfg.ProviderStrategy,
+		Quorum:           cfg.Quorum,
+		Stagger:          cfg.RaceStagger,
+		FailureThreshold: &cfg.FailureThreshold,
+		Cooldown:         cfg.ProviderCooldown,
+		Rotate:           cfg.RotateProviders,
+		AllowPrivate:     cfg.AllowPrivateIP,
+		OnDegraded:       u.providerDegraded,
+		OnRecovered:      u.providerRecovered,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ip providers: %w", err)
+	}
+	u.ipprovider = ipp
+
+	var notifiers notifier.Group
+	for _, v := range cfg.Notifications {
+		n, err := notifier.New(v)
+		if err != nil {
+			log.Warnf("failed to add a notifier: %s", err)
+			continue
+		}
+		notifiers = append(notifiers, n)
+	}
+	if len(notifiers) > 0 {
+		u.notifier = notifiers
+	}
+
+	if len(cfg.MQTT) > 0 {
+		p, err := mqtt.New(cfg.MQTT, cfg.RequestTimeout)
+		if err != nil {
+			log.Warnf("failed to add the mqtt publisher: %s", err)
+		} else {
+			u.publisher = p
+		}
+	}
+
+	if cfg.StateFile != "" {
+		u.state, err = state.Load(cfg.StateFile)
+		if err != nil {
+			log.Warnf("failed to load the state, starting from scratch: %s", err)
+			u.state = state.New()
+		}
+	}
+
+	if cfg.VerifyPropagation || cfg.ResolveBeforeUpdate {
+		u.checker = dnscheck.New(cfg.Nameservers, cfg.RequestTimeout)
+	}
+
+	return u, nil
 }
 
 // Start starts the updater process.
 func (u *Updater) Start(ctx context.Context) (err error) {
+	defer u.flush(ctx)
+
+	// verifications running in the background are canceled on shutdown
+	ctx, cancel := context.WithCancel(ctx)
+	defer u.verifying.Wait()
+	defer cancel()
+
 	log.Debug("initializing ip")
 
 	if _, err := u.ipUpdated(ctx); err != nil {
@@ -47,40 +150,126 @@ func (u *Updater) Start(ctx context.Context) (err error) {
 	}
 
 	log.Infof("current ip is %s", u.ip)
+	u.publishIP(ctx)
+	u.notify(ctx, notifier.Event{Type: notifier.EventStarted, NewIP: u.ip})
+
+	defer func() {
+		e := notifier.Event{Type: notifier.EventStopped, NewIP: u.ip}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		u.notify(ctx, e)
+	}()
+
+	if u.upToDate() {
+		log.Debugf("dns records are up to date according to the state, last sync at %s", u.state.LastSync.Format(time.RFC3339))
+	} else {
+		log.Debug("syncing dns records")
+		u.report(ctx, u.sync(ctx), "synced")
+	}
 
-	log.Debug("syncing dns records")
-	if err := u.sync(ctx); err != nil {
-		return fmt.Errorf("failed to sync dns records: %w", err)
+	// network changes trigger an immediate check,
+	// periodic checks remain as a fallback
+	var changes <-chan struct{}
+	if u.config.WatchNetwork && u.watcher != nil {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ch, err := u.watcher.Watch(watchCtx)
+		if err != nil {
+			log.Warnf("failed to watch network changes, only periodic checks are performed: %s", err)
+		}
+		changes = ch
+	}
+
+	// changes come in bursts, so the check is delayed until they settle,
+	// but not longer than maxSettleDelay after the first change
+	var settled <-chan time.Time
+	var settleBy time.Time
+
+	// drift is repaired in intervals, independently of IP changes
+	var resync <-chan time.Time
+	if u.config.ResyncPeriod > 0 {
+		t := time.NewTicker(u.config.ResyncPeriod)
+		defer t.Stop()
+		resync = t.C
 	}
-	log.Debug("done")
+
+	health := time.NewTicker(u.healthPeriod)
+	defer health.Stop()
 
 	// perform IP checks in intervals
 	for {
 		select {
 		case <-u.ticker.C:
-			log.Debugf("checking if ip (%s) has been updated", u.ip)
-
-			updated, err := u.ipUpdated(ctx)
-			if err != nil {
-				return fmt.Errorf("failed to get ip: %w", err)
+			if err := u.check(ctx); err != nil {
+				return err
 			}
-
-			if !updated {
+		case <-resync:
+			log.Debug("resyncing dns records")
+			u.report(ctx, u.resync(ctx), "repaired")
+		case _, ok := <-changes:
+			if !ok {
+				log.Warn("stopped watching network changes, only periodic checks are performed")
+				changes = nil
 				continue
 			}
-			log.Infof("ip has been updated to %s", u.ip)
-
-			log.Debug("updating dns records", u.ip)
-			if err := u.sync(ctx); err != nil {
-				return fmt.Errorf("failed to update dns records: %w", err)
+			if settled == nil {
+				settleBy = time.Now().Add(u.maxSettleDelay)
 			}
-			log.Debug("done")
+			settled = time.After(min(u.settleDelay, time.Until(settleBy)))
+		case <-settled:
+			settled = nil
+			log.Debug("network has changed")
+			// the network is often unreachable right after a change (e.g. the link is down),
+			// so the failure is not fatal, the next change or tick retries the check
+			if err := u.check(ctx); err != nil {
+				log.Warnf("failed to check ip after the network change: %s", err)
+			}
+		case <-health.C:
+			u.logHealth()
 		case <-u.shutdown:
 			return nil
 		}
 	}
 }
 
+// check syncs DNS records, if IP has been updated or the last sync failed.
+func (u *Updater) check(ctx context.Context) error {
+	log.Debugf("checking if ip (%s) has been updated", u.ip)
+
+	oldIP := u.ip
+	updated, err := u.ipUpdated(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get ip: %w", err)
+	}
+
+	if !updated && !u.pending {
+		return nil
+	}
+
+	if updated {
+		log.Infof("ip has been updated to %s", u.ip)
+		u.publishIP(ctx)
+	}
+
+	log.Debug("updating dns records")
+	report := u.sync(ctx)
+
+	if updated {
+		// records are synced first, so that the notification lists them
+		e := notifier.Event{Type: notifier.EventIPChanged, OldIP: oldIP, NewIP: u.ip}
+		for _, r := range report.Changed() {
+			e.Records = append(e.Records, notifier.RecordChange{Domain: r.Domain, Record: r.Record, OldData: r.OldData})
+		}
+		u.notify(ctx, e)
+	}
+
+	u.report(ctx, report, "updated")
+
+	return nil
+}
+
 // Stop stops the updater.
 func (u *Updater) Stop() {
 	u.ticker.Stop()
@@ -89,11 +278,19 @@ func (u *Updater) Stop() {
 
 // ipUpdated returns true and updates IP to a new value if IP changed.
 func (u *Updater) ipUpdated(ctx context.Context) (bool, error) {
-	newIP, err := u.ipprovider.GetIP(ctx)
+	ip, err := u.ipprovider.GetIP(ctx)
 	if err != nil {
 		return false, err
 	}
 
+	// compare canonical forms, so that formatting differences
+	// (e.g. zero compression in IPv6) are not treated as changes
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse ip: %w", err)
+	}
+	newIP := addr.Unmap().String()
+
 	if u.ip == newIP {
 		return false, nil
 	}
@@ -109,47 +306,526 @@ func match(a, b do.Record) bool {
 }
 
 // search searches for a record in records.
-// If success, returns record ID which is not 0.
-func (u *Updater) search(records []do.Record, record do.Record) uint64 {
+// If success, returns record which ID is not 0.
+func (u *Updater) search(records []do.Record, record do.Record) do.Record {
 	for _, r := range records {
 		if match(record, r) {
-			return r.ID
+			return r
 		}
 	}
 
-	return 0
+	return do.Record{}
+}
+
+// upToDate checks if the published record already has the desired data.
+func upToDate(published, desired do.Record) bool {
+	if published.Data != desired.Data {
+		return false
+	}
+
+	return desired.TTL == 0 || desired.TTL == published.TTL
 }
 
 // sync syncs DNS records.
-func (u *Updater) sync(ctx context.Context) error {
+// Domains are synced concurrently, a failure of one domain
+// doesn't stop the others, errors of all records are in the report.
+func (u *Updater) sync(ctx context.Context) SyncReport {
+	report := u.eachDomain(ctx, u.syncDomain)
+
+	u.synced(report.Err() == nil)
+	u.verifyInBackground(ctx, report.Changed())
+
+	return report
+}
+
+// syncDomain syncs DNS records of the domain.
+func (u *Updater) syncDomain(ctx context.Context, domain string) []RecordResult {
+	if u.config.ResolveBeforeUpdate {
+		if results, ok := u.published(ctx, domain); ok {
+			log.Debugf("records of the domain %s are already published, nothing to update", domain)
+			return results
+		}
+	}
+
+	if results, ok := u.updateKnown(ctx, domain); ok {
+		return results
+	}
+
+	return u.syncListed(ctx, domain)
+}
+
+// resync lists the records of all domains and repairs the ones
+// which drifted from the configuration, even if IP has not changed.
+func (u *Updater) resync(ctx context.Context) SyncReport {
+	report := u.eachDomain(ctx, u.syncListed)
+
+	u.synced(report.Err() == nil)
+	u.verifyInBackground(ctx, report.Changed())
+
+	return report
+}
+
+// eachDomain calls f for every domain concurrently, at most SyncWorkers at once,
+// and collects results of all domains.
+func (u *Updater) eachDomain(ctx context.Context, f func(context.Context, string) []RecordResult) SyncReport {
+	report := SyncReport{IP: u.ip, Started: time.Now()}
+
+	domains := make([]string, 0, len(u.config.Domains))
 	for domain := range u.config.Domains {
-		records, err := u.do.List(ctx, domain)
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	workers := u.config.SyncWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([][]RecordResult, len(domains))
+	sem := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for idx, domain := range domains {
+		wg.Add(1)
+		go func(idx int, domain string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[idx] = f(ctx, domain)
+		}(idx, domain)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		report.Records = append(report.Records, r...)
+	}
+	report.Duration = time.Since(report.Started)
+
+	return report
+}
+
+// syncListed lists DNS records of the domain, creates missing ones
+// and updates the ones which differ from the configuration.
+// A failure of one record doesn't stop the others.
+func (u *Updater) syncListed(ctx context.Context, domain string) []RecordResult {
+	configured := u.config.Domains[domain]
+	results := make([]RecordResult, 0, len(configured))
+
+	started := time.Now()
+	records, err := u.do.List(ctx, domain)
+	if err != nil {
+		err = fmt.Errorf("failed to get the records for the domain %s: %w", domain, err)
+		for _, r := range configured {
+			results = append(results, RecordResult{Domain: domain, Record: r, Action: ActionFailed, Duration: time.Since(started), Err: err})
+		}
+		return results
+	}
+
+	for _, r := range configured {
+		res := u.syncRecord(ctx, domain, r, records)
+		res.Duration = time.Since(started)
+		results = append(results, res)
+		started = time.Now()
+	}
+
+	return results
+}
+
+// syncRecord creates or updates the record, if it differs from the published one.
+func (u *Updater) syncRecord(ctx context.Context, domain string, r do.Record, records []do.Record) RecordResult {
+	res := RecordResult{Domain: domain, Record: r, Action: ActionFailed}
+
+	data, err := u.prepareData(r, u.config.Params)
+	if err != nil {
+		res.Err = fmt.Errorf("failed to set data to the record %s of the domain %s: %w", r.Type, domain, err)
+		return res
+	}
+	r.Data = data
+	res.Record = r
+	res.NewData = data
+
+	published := u.search(records, r)
+	if published.ID == 0 {
+		if err := u.do.Create(ctx, domain, r); err != nil {
+			res.Err = fmt.Errorf("failed to create the record %s %s for the domain %s: %w", r.Type, r.Name, domain, err)
+			return res
+		}
+		u.remember(domain, r)
+		res.Action = ActionCreated
+		return res
+	}
+
+	r.ID = published.ID
+	res.Record = r
+	res.OldData = published.Data
+	if upToDate(published, r) {
+		u.remember(domain, r)
+		res.Action = ActionUnchanged
+		return res
+	}
+
+	if err := u.do.Update(ctx, domain, r); err != nil {
+		res.Err = fmt.Errorf("failed to update the record %s %s for the domain %s: %w", r.Type, r.Name, domain, err)
+		return res
+	}
+	u.remember(domain, r)
+	res.Action = ActionUpdated
+
+	return res
+}
+
+// report logs and notifies about results of the sync,
+// changed records are logged with the verb.
+// If some records failed, the sync is retried on the next check.
+func (u *Updater) report(ctx context.Context, report SyncReport, verb string) {
+	for _, r := range report.Records {
+		if !r.Changed() {
+			log.Debugf("record %s", r)
+			continue
+		}
+
+		log.Infof("%s the record %s", verb, r)
+		u.notify(ctx, notifier.Event{
+			Type:    notifier.EventRecordUpdated,
+			NewIP:   report.IP,
+			Domain:  r.Domain,
+			Record:  r.Record,
+			OldData: r.OldData,
+		})
+	}
+
+	err := report.Err()
+	u.publishSync(ctx, report, err)
+
+	failed := u.pending
+	u.pending = err != nil
+	if err != nil {
+		log.Errorf("failed to sync dns records: %s", err)
+		u.notify(ctx, notifier.Event{Type: notifier.EventSyncFailed, NewIP: report.IP, Error: err.Error()})
+		return
+	}
+
+	if failed {
+		log.Info("dns records are synced again")
+		u.notify(ctx, notifier.Event{Type: notifier.EventSyncRecovered, NewIP: report.IP})
+	}
+
+	log.Debugf("done: %s", report)
+}
+
+// notify notifies about the event, failures are only logged.
+// The event is sent even if ctx is canceled, e.g. on shutdown.
+func (u *Updater) notify(ctx context.Context, e notifier.Event) {
+	if u.notifier == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.config.RequestTimeout)
+	defer cancel()
+
+	e.Time = time.Now()
+	if err := u.notifier.Notify(ctx, e); err != nil {
+		log.Warnf("failed to notify about the event %s: %s", e.Type, err)
+	}
+}
+
+// flush delivers pending notifications and marks ddns as offline in MQTT.
+func (u *Updater) flush(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+	defer cancel()
+
+	if u.notifier != nil {
+		if err := u.notifier.Close(ctx); err != nil {
+			log.Warnf("failed to deliver notifications: %s", err)
+		}
+	}
+
+	if u.publisher != nil {
+		if err := u.publisher.Close(ctx); err != nil {
+			log.Warnf("failed to disconnect from mqtt: %s", err)
+		}
+	}
+}
+
+// publishIP publishes the current IP to MQTT, failures are only logged.
+func (u *Updater) publishIP(ctx context.Context) {
+	if u.publisher == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.config.RequestTimeout)
+	defer cancel()
+
+	if err := u.publisher.PublishIP(ctx, u.ip); err != nil {
+		log.Warnf("failed to publish ip to mqtt: %s", err)
+	}
+}
+
+// publishSync publishes the result of the sync to MQTT, failures are only logged.
+func (u *Updater) publishSync(ctx context.Context, report SyncReport, err error) {
+	if u.publisher == nil {
+		return
+	}
+
+	s := mqtt.SyncStatus{IP: report.IP, Time: report.Started, Duration: report.Duration}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	for _, r := range report.Records {
+		rs := mqtt.RecordStatus{Domain: r.Domain, Type: r.Record.Type, Name: r.Record.Name, Data: r.NewData, Action: string(r.Action)}
+		if r.Err != nil {
+			rs.Error = r.Err.Error()
+		}
+		s.Records = append(s.Records, rs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.config.RequestTimeout)
+	defer cancel()
+
+	if err := u.publisher.PublishSync(ctx, s); err != nil {
+		log.Warnf("failed to publish the sync result to mqtt: %s", err)
+	}
+}
+
+// logHealth logs success rate, latency and the last error of every IP provider.
+func (u *Updater) logHealth() {
+	hr, ok := u.ipprovider.(ipprovider.HealthReporter)
+	if !ok {
+		return
+	}
+
+	for _, h := range hr.Health() {
+		log.Infof("ip provider %s", h)
+	}
+}
+
+// providerDegraded notifies that the IP provider has been demoted.
+func (u *Updater) providerDegraded(h ipprovider.ProviderHealth) {
+	u.notify(context.Background(), notifier.Event{
+		Type:     notifier.EventProviderDegraded,
+		Provider: h.Name,
+		Error:    h.LastError,
+	})
+}
+
+// providerRecovered notifies that the demoted IP provider succeeds again.
+func (u *Updater) providerRecovered(h ipprovider.ProviderHealth) {
+	u.notify(context.Background(), notifier.Event{
+		Type:     notifier.EventProviderRecovered,
+		Provider: h.Name,
+	})
+}
+
+// verifyInBackground verifies propagation of the changed records without blocking
+// the updater, so that checks and shutdown are not delayed by slow propagation.
+func (u *Updater) verifyInBackground(ctx context.Context, changes []RecordResult) {
+	if !u.config.VerifyPropagation || len(changes) == 0 {
+		return
+	}
+
+	u.verifying.Add(1)
+	go func() {
+		defer u.verifying.Done()
+		u.verify(ctx, changes)
+	}()
+}
+
+// verify waits until the changed records are served
+// by the authoritative nameservers and reports the result.
+func (u *Updater) verify(ctx context.Context, changes []RecordResult) {
+	ctx, cancel := context.WithTimeout(ctx, u.config.PropagationTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, c := range changes {
+		wg.Add(1)
+		go func(c RecordResult) {
+			defer wg.Done()
+
+			started := time.Now()
+			err := u.checker.Wait(ctx, c.Domain, c.Record)
+			switch {
+			case errors.Is(err, dnscheck.ErrUnsupported):
+				log.Debugf("propagation of the record %s %s of the domain %s is not verified: %s", c.Record.Type, c.Record.Name, c.Domain, err)
+			case err != nil:
+				log.Warnf("record %s %s of the domain %s has not propagated in %s: %s", c.Record.Type, c.Record.Name, c.Domain, time.Since(started).Round(time.Second), err)
+			default:
+				log.Infof("record %s %s of the domain %s has propagated in %s", c.Record.Type, c.Record.Name, c.Domain, time.Since(started).Round(time.Second))
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+// synced stores the result of the sync in the state.
+// IP is stored only if all records have been synced,
+// so that the failed ones are synced again after restart.
+func (u *Updater) synced(ok bool) {
+	if u.state == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if ok {
+		u.state.IP = u.ip
+		u.state.LastSync = time.Now()
+	}
+	u.saveState()
+}
+
+// updateKnown updates records of the domain using IDs from the state,
+// so that listing of the records is not needed.
+// Records which are up to date according to the state are not updated.
+// It returns false, if some ID is unknown or update failed.
+func (u *Updater) updateKnown(ctx context.Context, domain string) ([]RecordResult, bool) {
+	if u.state == nil {
+		return nil, false
+	}
+
+	results := make([]RecordResult, 0, len(u.config.Domains[domain]))
+	for _, r := range u.config.Domains[domain] {
+		u.mu.Lock()
+		known, ok := u.state.Get(domain, r.Type, r.Name)
+		u.mu.Unlock()
+		if !ok || known.ID == 0 {
+			return nil, false
+		}
+
+		data, err := u.prepareData(r, u.config.Params)
 		if err != nil {
-			return fmt.Errorf("failed to get the records for the domain %s: %w", domain, err)
+			return nil, false
 		}
 
-		for _, r := range u.config.Domains[domain] {
-			r.Data, err = u.prepareData(r, u.config.Params)
-			if err != nil {
-				return fmt.Errorf("failed to set data to the record %s of the domain %s: %w", domain, r.Type, err)
+		r.ID = known.ID
+		r.Data = data
+		action := ActionUpdated
+		if knownUpToDate(known, r) {
+			action = ActionUnchanged
+		}
+		results = append(results, RecordResult{Domain: domain, Record: r, Action: action, OldData: known.Data, NewData: data})
+	}
+
+	for idx, res := range results {
+		if res.Action == ActionUnchanged {
+			continue
+		}
+
+		started := time.Now()
+		if err := u.do.Update(ctx, domain, res.Record); err != nil {
+			// the record could have been deleted, so list records and try again
+			log.Debugf("failed to update the record %s %s of the domain %s by known id: %s", res.Record.Type, res.Record.Name, domain, err)
+			return nil, false
+		}
+		u.remember(domain, res.Record)
+		results[idx].Duration = time.Since(started)
+	}
+
+	return results, true
+}
+
+// published reports whether all records of the domain are already
+// served by the authoritative nameservers with the desired data.
+// If so, records are reported as skipped.
+func (u *Updater) published(ctx context.Context, domain string) ([]RecordResult, bool) {
+	results := make([]RecordResult, 0, len(u.config.Domains[domain]))
+	for _, r := range u.config.Domains[domain] {
+		started := time.Now()
+
+		data, err := u.prepareData(r, u.config.Params)
+		if err != nil {
+			return nil, false
+		}
+		r.Data = data
+
+		// TTL is not resolved, so a changed TTL is noticed only with the state
+		if r.TTL != 0 && !u.knownTTL(domain, r) {
+			return nil, false
+		}
+
+		ok, err := u.checker.Published(ctx, domain, r)
+		if err != nil {
+			log.Debugf("failed to resolve the record %s %s of the domain %s: %s", r.Type, r.Name, domain, err)
+			return nil, false
+		}
+
+		if !ok {
+			return nil, false
+		}
+
+		results = append(results, RecordResult{Domain: domain, Record: r, Action: ActionSkipped, OldData: data, NewData: data, Duration: time.Since(started)})
+	}
+
+	return results, true
+}
+
+// remember stores the published record in the state.
+func (u *Updater) remember(domain string, r do.Record) {
+	if u.state == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.state.Set(domain, r.Type, r.Name, state.Record{ID: r.ID, Data: r.Data, TTL: r.TTL})
+}
+
+// knownTTL reports whether the record is known from the state to be published with the desired TTL.
+func (u *Updater) knownTTL(domain string, r do.Record) bool {
+	if u.state == nil {
+		return false
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	known, ok := u.state.Get(domain, r.Type, r.Name)
+	return ok && known.TTL == r.TTL
+}
+
+// knownUpToDate checks if the record known from the state already has the desired data and TTL.
+func knownUpToDate(known state.Record, desired do.Record) bool {
+	return upToDate(do.Record{Data: known.Data, TTL: known.TTL}, desired)
+}
+
+// upToDate reports whether, according to the state,
+// all configured records are already published with the current IP.
+func (u *Updater) upToDate() bool {
+	if u.state == nil || u.state.IP != u.ip {
+		return false
+	}
+
+	for domain, records := range u.config.Domains {
+		for _, r := range records {
+			known, ok := u.state.Get(domain, r.Type, r.Name)
+			if !ok {
+				return false
 			}
 
-			recordID := u.search(records, r)
-			if recordID == 0 {
-				if err := u.do.Create(ctx, domain, r); err != nil {
-					return fmt.Errorf("failed to create a record for the domain %s: %w", domain, err)
-				}
-				continue
+			data, err := u.prepareData(r, u.config.Params)
+			if err != nil {
+				return false
 			}
 
-			r.ID = recordID
-			if err := u.do.Update(ctx, domain, r); err != nil {
-				return fmt.Errorf("failed to update a record for the domain %s: %w", domain, err)
+			r.Data = data
+			if !knownUpToDate(known, r) {
+				return false
 			}
 		}
 	}
 
-	return nil
+	return true
+}
+
+// saveState writes the state to the state file.
+func (u *Updater) saveState() {
+	if err := u.state.Save(u.config.StateFile); err != nil {
+		log.Warnf("failed to save the state: %s", err)
+	}
 }
 
 // prepareData executes template and return what should be set in the DNS record data field.
@@ -159,7 +835,13 @@ func (u *Updater) prepareData(configRecord do.Record, params map[string]string)
 		return u.ip, nil
 	}
 
-	params["IP"] = u.ip
+	// params are shared by concurrently synced domains,
+	// so IP is added to a copy of them
+	data := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		data[k] = v
+	}
+	data["IP"] = u.ip
 
 	t, err := template.New("t1").Parse(configRecord.Data)
 	if err != nil {
@@ -167,7 +849,7 @@ func (u *Updater) prepareData(configRecord do.Record, params map[string]string)
 	}
 
 	buf := new(bytes.Buffer)
-	if err := t.Execute(buf, params); err != nil {
+	if err := t.Execute(buf, data); err != nil {
 		return "", fmt.Errorf("failed to execute a template: %w", err)
 	}
 
@@ -2,12 +2,22 @@ package updater
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/matryer/is"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/skibish/ddns/conf"
 	"github.com/skibish/ddns/do"
+	"github.com/skibish/ddns/ipprovider"
+	"github.com/skibish/ddns/mqtt"
+	"github.com/skibish/ddns/notifier"
+	"github.com/skibish/ddns/state"
 )
 
 func TestUpdater(t *testing.T) {
@@ -117,7 +127,8 @@ func TestUpdater(t *testing.T) {
 			getIPidx = 0
 			is := is.New(t)
 
-			u := New(tc.cfg)
+			u, err := New(tc.cfg)
+			is.NoErr(err)
 			u.do = tc.dm
 			u.ipprovider = tc.pm
 
@@ -126,7 +137,7 @@ func TestUpdater(t *testing.T) {
 				u.Stop()
 			}()
 
-			err := u.Start(context.Background())
+			err = u.Start(context.Background())
 
 			is.NoErr(err)
 			is.Equal(len(tc.pm.GetIPCalls()), tc.pmGetIPCalls)
@@ -204,3 +215,1030 @@ func TestUpdaterPrepareData(t *testing.T) {
 	}
 
 }
+
+func TestUpdaterIPUpdated(t *testing.T) {
+	tcases := []struct {
+		tname    string
+		ip       string
+		newIP    string
+		updated  bool
+		expected string
+		isErr    bool
+	}{
+		{"ok updated", "10.0.0.1", "10.0.0.2", true, "10.0.0.2", false},
+		{"ok same", "10.0.0.1", "10.0.0.1", false, "10.0.0.1", false},
+		{"ok same ipv6 formatting", "2001:db8::1", "2001:0DB8:0:0:0:0:0:1", false, "2001:db8::1", false},
+		{"invalid ip", "10.0.0.1", "<html></html>", false, "10.0.0.1", true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			u := &Updater{
+				ip: tc.ip,
+				ipprovider: &ProviderMock{
+					GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+						return tc.newIP, nil
+					},
+				},
+			}
+
+			updated, err := u.ipUpdated(context.Background())
+			if tc.isErr {
+				if err == nil {
+					is.Fail() // should be error
+				}
+				return
+			}
+
+			is.NoErr(err)
+			is.Equal(updated, tc.updated)
+			is.Equal(u.ip, tc.expected)
+		})
+	}
+}
+
+// fakeWatcher is a netwatch.Watcher which emits changes on demand.
+type fakeWatcher struct {
+	changes chan struct{}
+	err     error
+}
+
+func (f *fakeWatcher) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return f.changes, f.err
+}
+
+func TestUpdaterWatchNetwork(t *testing.T) {
+	tcases := []struct {
+		tname        string
+		watch        bool
+		watchErr     error
+		getIPErr     error
+		changes      int
+		pmGetIPCalls int
+		dmListCalls  int
+	}{
+		{"ok change triggers check", true, nil, nil, 1, 2, 2},
+		{"ok burst is merged", true, nil, nil, 5, 2, 2},
+		{"ok failed check keeps running", true, nil, errors.New("network is unreachable"), 1, 2, 1},
+		{"disabled", false, nil, nil, 1, 1, 1},
+		{"watch failed", true, errors.New("not supported"), nil, 0, 1, 1},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			ips := []string{"10.0.5.1", "10.0.0.1"}
+			var getIPidx int
+			pm := &ProviderMock{
+				GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+					// IP is unavailable after the link has gone down
+					if getIPidx > 0 && tc.getIPErr != nil {
+						return "", tc.getIPErr
+					}
+					ip := ips[getIPidx%len(ips)]
+					getIPidx++
+					return ip, nil
+				},
+			}
+			dm := &DomainsServiceMock{
+				CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+				ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+					return []do.Record{}, nil
+				},
+			}
+
+			u, err := New(&conf.Configuration{
+				Domains: map[string][]do.Record{
+					"example.com": {{Type: "A", Name: "ddns"}},
+				},
+				CheckPeriod:    1 * time.Hour,
+				RequestTimeout: 5 * time.Second,
+				WatchNetwork:   tc.watch,
+			})
+			is.NoErr(err)
+
+			w := &fakeWatcher{changes: make(chan struct{}), err: tc.watchErr}
+			u.do = dm
+			u.ipprovider = pm
+			u.watcher = w
+			u.settleDelay = 50 * time.Millisecond
+
+			go func() {
+				for n := 0; n < tc.changes; n++ {
+					select {
+					case w.changes <- struct{}{}:
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+				time.Sleep(300 * time.Millisecond)
+				u.Stop()
+			}()
+
+			err = u.Start(context.Background())
+
+			is.NoErr(err)
+			is.Equal(len(pm.GetIPCalls()), tc.pmGetIPCalls)
+			is.Equal(len(dm.ListCalls()), tc.dmListCalls)
+		})
+	}
+}
+
+func TestUpdaterSettleLimit(t *testing.T) {
+	is := is.New(t)
+
+	start := time.Now()
+	checked := make(chan time.Duration, 10)
+	pm := &ProviderMock{
+		GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+			checked <- time.Since(start)
+			return "10.0.5.1", nil
+		},
+	}
+	dm := &DomainsServiceMock{
+		CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return nil
+		},
+		ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+			return []do.Record{}, nil
+		},
+	}
+
+	u, err := New(&conf.Configuration{
+		Domains: map[string][]do.Record{
+			"example.com": {{Type: "A", Name: "ddns"}},
+		},
+		CheckPeriod:    1 * time.Hour,
+		RequestTimeout: 5 * time.Second,
+		WatchNetwork:   true,
+	})
+	is.NoErr(err)
+
+	w := &fakeWatcher{changes: make(chan struct{})}
+	u.do = dm
+	u.ipprovider = pm
+	u.watcher = w
+	u.settleDelay = 50 * time.Millisecond
+	u.maxSettleDelay = 100 * time.Millisecond
+
+	done := make(chan error)
+	go func() {
+		done <- u.Start(context.Background())
+	}()
+
+	// changes never settle
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case w.changes <- struct{}{}:
+			case <-stop:
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	<-checked // initial check
+	select {
+	case <-checked:
+	case <-time.After(time.Second):
+		is.Fail() // ip is not checked while changes continue
+	}
+
+	close(stop)
+	u.Stop()
+	is.NoErr(<-done)
+}
+
+func TestUpdaterState(t *testing.T) {
+	tcases := []struct {
+		tname string
+		state *state.State
+		// ttl is the configured TTL of the A record.
+		ttl           uint64
+		updateErr     error
+		dmCreateCalls int
+		dmUpdateCalls int
+		dmListCalls   int
+	}{
+		{
+			tname:         "no state",
+			state:         nil,
+			dmCreateCalls: 2,
+			dmListCalls:   1,
+		},
+		{
+			tname: "up to date",
+			state: &state.State{
+				IP: "10.0.5.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns":   {ID: 123, Data: "10.0.5.1"},
+						"TXT/ddns": {ID: 124, Data: "ip=10.0.5.1"},
+					},
+				},
+			},
+		},
+		{
+			tname: "known ids",
+			state: &state.State{
+				IP: "10.0.0.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns":   {ID: 123, Data: "10.0.0.1"},
+						"TXT/ddns": {ID: 124, Data: "ip=10.0.0.1"},
+					},
+				},
+			},
+			dmUpdateCalls: 2,
+		},
+		{
+			tname: "known ids partially up to date",
+			state: &state.State{
+				IP: "10.0.0.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns":   {ID: 123, Data: "10.0.5.1"},
+						"TXT/ddns": {ID: 124, Data: "ip=10.0.0.1"},
+					},
+				},
+			},
+			dmUpdateCalls: 1,
+		},
+		{
+			tname: "ttl changed in configuration",
+			state: &state.State{
+				IP: "10.0.5.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns":   {ID: 123, Data: "10.0.5.1", TTL: 1800},
+						"TXT/ddns": {ID: 124, Data: "ip=10.0.5.1"},
+					},
+				},
+			},
+			ttl:           60,
+			dmUpdateCalls: 1,
+		},
+		{
+			tname: "ttl up to date",
+			state: &state.State{
+				IP: "10.0.5.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns":   {ID: 123, Data: "10.0.5.1", TTL: 60},
+						"TXT/ddns": {ID: 124, Data: "ip=10.0.5.1"},
+					},
+				},
+			},
+			ttl: 60,
+		},
+		{
+			tname: "known ids update failed",
+			state: &state.State{
+				IP: "10.0.0.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns":   {ID: 123, Data: "10.0.0.1"},
+						"TXT/ddns": {ID: 124, Data: "ip=10.0.0.1"},
+					},
+				},
+			},
+			updateErr:     errors.New("not found"),
+			dmUpdateCalls: 1,
+			dmCreateCalls: 2,
+			dmListCalls:   1,
+		},
+		{
+			tname: "record added to configuration",
+			state: &state.State{
+				IP: "10.0.5.1",
+				Records: map[string]map[string]state.Record{
+					"example.com": {
+						"A/ddns": {ID: 123, Data: "10.0.5.1"},
+					},
+				},
+			},
+			dmCreateCalls: 2,
+			dmListCalls:   1,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			cfg := &conf.Configuration{
+				Domains: map[string][]do.Record{
+					"example.com": {
+						{Type: "A", Name: "ddns", TTL: tc.ttl},
+						{Type: "TXT", Name: "ddns", Data: "ip={{.IP}}"},
+					},
+				},
+				Params:         make(map[string]string),
+				CheckPeriod:    1 * time.Hour,
+				RequestTimeout: 5 * time.Second,
+			}
+			if tc.state != nil {
+				is.NoErr(tc.state.Save(path))
+				cfg.StateFile = path
+			}
+
+			dm := &DomainsServiceMock{
+				CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+				UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return tc.updateErr
+				},
+				ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+					return []do.Record{}, nil
+				},
+			}
+
+			u, err := New(cfg)
+			is.NoErr(err)
+			u.do = dm
+			u.ipprovider = &ProviderMock{
+				GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+					return "10.0.5.1", nil
+				},
+			}
+
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				u.Stop()
+			}()
+
+			err = u.Start(context.Background())
+
+			is.NoErr(err)
+			is.Equal(len(dm.CreateCalls()), tc.dmCreateCalls)
+			is.Equal(len(dm.UpdateCalls()), tc.dmUpdateCalls)
+			is.Equal(len(dm.ListCalls()), tc.dmListCalls)
+
+			if tc.state == nil {
+				return
+			}
+
+			// state is up to date after sync
+			st, err := state.Load(path)
+			is.NoErr(err)
+			is.Equal(st.IP, "10.0.5.1")
+
+			r, ok := st.Get("example.com", "TXT", "ddns")
+			is.True(ok)
+			is.Equal(r.Data, "ip=10.0.5.1")
+
+			r, ok = st.Get("example.com", "A", "ddns")
+			is.True(ok)
+			is.Equal(r.TTL, tc.ttl)
+		})
+	}
+}
+
+func TestUpdaterResync(t *testing.T) {
+	tcases := []struct {
+		tname         string
+		resyncPeriod  time.Duration
+		published     []string
+		dmUpdateCalls int
+		dmListCalls   int
+	}{
+		{"ok no drift", 200 * time.Millisecond, []string{"10.0.5.1", "10.0.5.1"}, 0, 2},
+		{"ok drift repaired", 200 * time.Millisecond, []string{"10.0.5.1", "1.1.1.1"}, 1, 2},
+		{"ok initial drift", 200 * time.Millisecond, []string{"1.1.1.1", "10.0.5.1"}, 1, 2},
+		{"disabled", 0, []string{"10.0.5.1", "1.1.1.1"}, 0, 1},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			var listIdx int
+			dm := &DomainsServiceMock{
+				UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+				ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+					data := tc.published[listIdx%len(tc.published)]
+					listIdx++
+					return []do.Record{
+						{ID: 123, Type: "A", Name: "ddns", Data: data, TTL: 1800},
+					}, nil
+				},
+			}
+
+			u, err := New(&conf.Configuration{
+				Domains: map[string][]do.Record{
+					"example.com": {{Type: "A", Name: "ddns"}},
+				},
+				CheckPeriod:    1 * time.Hour,
+				ResyncPeriod:   tc.resyncPeriod,
+				RequestTimeout: 5 * time.Second,
+			})
+			is.NoErr(err)
+			u.do = dm
+			u.ipprovider = &ProviderMock{
+				GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+					return "10.0.5.1", nil
+				},
+			}
+
+			go func() {
+				time.Sleep(300 * time.Millisecond)
+				u.Stop()
+			}()
+
+			err = u.Start(context.Background())
+
+			is.NoErr(err)
+			is.Equal(len(dm.UpdateCalls()), tc.dmUpdateCalls)
+			is.Equal(len(dm.ListCalls()), tc.dmListCalls)
+		})
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	is := is.New(t)
+
+	published := do.Record{ID: 1, Type: "A", Name: "www", Data: "10.0.0.1", TTL: 1800}
+
+	is.True(upToDate(published, do.Record{Type: "A", Name: "www", Data: "10.0.0.1"}))
+	is.True(upToDate(published, do.Record{Type: "A", Name: "www", Data: "10.0.0.1", TTL: 1800}))
+	is.True(!upToDate(published, do.Record{Type: "A", Name: "www", Data: "10.0.0.2"}))
+	is.True(!upToDate(published, do.Record{Type: "A", Name: "www", Data: "10.0.0.1", TTL: 60}))
+}
+
+// fakeChecker is a dnscheck.Checker which records checked records.
+type fakeChecker struct {
+	mu           sync.Mutex
+	published    map[string]bool
+	publishedErr error
+	waited       []string
+	waitErr      error
+	// block makes Wait wait until ctx is done.
+	block bool
+}
+
+func (f *fakeChecker) Published(ctx context.Context, domain string, r do.Record) (bool, error) {
+	return f.published[r.Type+" "+r.Name+" "+r.Data], f.publishedErr
+}
+
+func (f *fakeChecker) Wait(ctx context.Context, domain string, r do.Record) error {
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.waited = append(f.waited, r.Type+" "+r.Name+" "+r.Data)
+	return f.waitErr
+}
+
+func TestUpdaterVerify(t *testing.T) {
+	tcases := []struct {
+		tname    string
+		waitErr  error
+		expected []string
+	}{
+		{"ok propagated", nil, []string{"A new 10.0.5.1", "A old 10.0.5.1"}},
+		{"not propagated", errors.New("timeout"), []string{"A new 10.0.5.1", "A old 10.0.5.1"}},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			u, err := New(&conf.Configuration{
+				Domains: map[string][]do.Record{
+					"example.com": {
+						{Type: "A", Name: "new"},
+						{Type: "A", Name: "old"},
+						{Type: "A", Name: "same"},
+					},
+				},
+				CheckPeriod:        1 * time.Hour,
+				RequestTimeout:     5 * time.Second,
+				PropagationTimeout: 1 * time.Second,
+				VerifyPropagation:  true,
+			})
+			is.NoErr(err)
+			is.True(u.checker != nil)
+
+			checker := &fakeChecker{waitErr: tc.waitErr}
+			u.checker = checker
+			u.ip = "10.0.5.1"
+			u.do = &DomainsServiceMock{
+				CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+				UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+				ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+					return []do.Record{
+						{ID: 1, Type: "A", Name: "old", Data: "10.0.0.1"},
+						{ID: 2, Type: "A", Name: "same", Data: "10.0.5.1"},
+					}, nil
+				},
+			}
+
+			is.NoErr(u.sync(context.Background()).Err())
+			u.verifying.Wait()
+
+			sort.Strings(checker.waited)
+			is.Equal(checker.waited, tc.expected)
+		})
+	}
+}
+
+func TestUpdaterVerifyDoesNotBlock(t *testing.T) {
+	is := is.New(t)
+
+	u, err := New(&conf.Configuration{
+		Domains: map[string][]do.Record{
+			"example.com": {{Type: "A", Name: "new"}},
+		},
+		CheckPeriod:        1 * time.Hour,
+		RequestTimeout:     5 * time.Second,
+		PropagationTimeout: 1 * time.Hour,
+		VerifyPropagation:  true,
+	})
+	is.NoErr(err)
+
+	u.checker = &fakeChecker{block: true}
+	u.ipprovider = &ProviderMock{
+		GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+			return "10.0.5.1", nil
+		},
+	}
+	u.do = &DomainsServiceMock{
+		CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return nil
+		},
+		ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+			return []do.Record{}, nil
+		},
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		u.Stop()
+	}()
+
+	done := make(chan error)
+	go func() {
+		done <- u.Start(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		is.NoErr(err)
+	case <-time.After(2 * time.Second):
+		is.Fail() // shutdown is blocked by the verification
+	}
+}
+
+func TestUpdaterResolveBeforeUpdate(t *testing.T) {
+	tcases := []struct {
+		tname        string
+		published    map[string]bool
+		publishedErr error
+		// ttl is the configured TTL of the A record and knownTTL is its TTL in the state.
+		ttl           uint64
+		knownTTL      uint64
+		dmListCalls   int
+		dmUpdateCalls int
+	}{
+		{
+			tname:     "all published",
+			published: map[string]bool{"A www 10.0.5.1": true, "TXT www ip=10.0.5.1": true},
+		},
+		{
+			tname:     "all published with known ttl",
+			published: map[string]bool{"A www 10.0.5.1": true, "TXT www ip=10.0.5.1": true},
+			ttl:       300,
+			knownTTL:  300,
+		},
+		{
+			tname:         "ttl changed",
+			published:     map[string]bool{"A www 10.0.5.1": true, "TXT www ip=10.0.5.1": true},
+			ttl:           300,
+			knownTTL:      60,
+			dmListCalls:   1,
+			dmUpdateCalls: 2,
+		},
+		{
+			tname:         "one is not published",
+			published:     map[string]bool{"A www 10.0.5.1": true},
+			dmListCalls:   1,
+			dmUpdateCalls: 2,
+		},
+		{
+			tname:         "resolve failed",
+			published:     map[string]bool{"A www 10.0.5.1": true, "TXT www ip=10.0.5.1": true},
+			publishedErr:  errors.New("timeout"),
+			dmListCalls:   1,
+			dmUpdateCalls: 2,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			u, err := New(&conf.Configuration{
+				Domains: map[string][]do.Record{
+					"example.com": {
+						{Type: "A", Name: "www", TTL: tc.ttl},
+						{Type: "TXT", Name: "www", Data: "ip={{.IP}}"},
+					},
+				},
+				Params:              make(map[string]string),
+				CheckPeriod:         1 * time.Hour,
+				RequestTimeout:      5 * time.Second,
+				ResolveBeforeUpdate: true,
+				StateFile:           filepath.Join(t.TempDir(), "state.json"),
+			})
+			is.NoErr(err)
+			is.True(u.checker != nil)
+			u.state.Set("example.com", "A", "www", state.Record{ID: 1, Data: "10.0.5.1", TTL: tc.knownTTL})
+
+			dm := &DomainsServiceMock{
+				UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+				ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+					return []do.Record{
+						{ID: 1, Type: "A", Name: "www", Data: "10.0.0.1"},
+						{ID: 2, Type: "TXT", Name: "www", Data: "ip=10.0.0.1"},
+					}, nil
+				},
+			}
+
+			u.checker = &fakeChecker{published: tc.published, publishedErr: tc.publishedErr}
+			u.ip = "10.0.5.1"
+			u.do = dm
+
+			is.NoErr(u.sync(context.Background()).Err())
+			is.Equal(len(dm.ListCalls()), tc.dmListCalls)
+			is.Equal(len(dm.UpdateCalls()), tc.dmUpdateCalls)
+		})
+	}
+}
+
+func TestUpdaterSyncConcurrently(t *testing.T) {
+	tcases := []struct {
+		tname       string
+		workers     int
+		failing     string
+		maxInFlight int32
+		errExpected bool
+	}{
+		{"ok", 2, "", 2, false},
+		{"ok sequential", 0, "", 1, false},
+		{"one domain fails", 4, "b.com", 4, true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.tname, func(t *testing.T) {
+			is := is.New(t)
+
+			var inFlight, maxInFlight atomic.Int32
+			dm := &DomainsServiceMock{
+				ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+					n := inFlight.Add(1)
+					defer inFlight.Add(-1)
+					for {
+						m := maxInFlight.Load()
+						if n <= m || maxInFlight.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					time.Sleep(50 * time.Millisecond)
+
+					if s == tc.failing {
+						return nil, errors.New("api is down")
+					}
+					return []do.Record{}, nil
+				},
+				CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+					return nil
+				},
+			}
+
+			u, err := New(&conf.Configuration{
+				Domains: map[string][]do.Record{
+					"a.com": {{Type: "A", Name: "ddns"}},
+					"b.com": {{Type: "A", Name: "ddns"}},
+					"c.com": {{Type: "A", Name: "ddns"}, {Type: "TXT", Name: "ddns"}},
+					"d.com": {{Type: "A", Name: "ddns"}},
+				},
+				SyncWorkers:    tc.workers,
+				CheckPeriod:    1 * time.Hour,
+				RequestTimeout: 5 * time.Second,
+			})
+			is.NoErr(err)
+			u.do = dm
+			u.ip = "10.0.5.1"
+
+			report := u.sync(context.Background())
+
+			is.Equal(report.Err() != nil, tc.errExpected)
+			is.Equal(len(dm.ListCalls()), 4)
+			is.Equal(maxInFlight.Load(), tc.maxInFlight)
+
+			created := 5
+			if tc.failing != "" {
+				created--
+			}
+			is.Equal(len(dm.CreateCalls()), created)
+			is.Equal(report.Count(ActionCreated), created)
+			is.Equal(report.Count(ActionFailed), 5-created)
+			is.Equal(len(report.Records), 5)
+			is.Equal(report.Records[0].Domain, "a.com") // records are ordered by domain
+		})
+	}
+}
+
+type fakeNotifier struct {
+	mu     sync.Mutex
+	events []notifier.Event
+	closed bool
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, e notifier.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.events = append(n.events, e)
+	return nil
+}
+
+func (n *fakeNotifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.closed = true
+	return nil
+}
+
+func (n *fakeNotifier) types() []notifier.EventType {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	res := make([]notifier.EventType, 0, len(n.events))
+	for _, e := range n.events {
+		res = append(res, e.Type)
+	}
+	return res
+}
+
+func TestUpdaterNotify(t *testing.T) {
+	is := is.New(t)
+
+	var listCalls atomic.Int32
+	dm := &DomainsServiceMock{
+		ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+			// the second sync fails and is retried on the next check
+			if listCalls.Add(1) == 2 {
+				return nil, errors.New("api is down")
+			}
+			return []do.Record{{ID: 123, Type: "A", Name: "ddns", Data: "10.0.5.1"}}, nil
+		},
+		UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return nil
+		},
+	}
+
+	var getIPCalls atomic.Int32
+	pm := &ProviderMock{
+		GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+			if getIPCalls.Add(1) == 1 {
+				return "10.0.5.1", nil
+			}
+			return "10.0.5.2", nil
+		},
+	}
+
+	u, err := New(&conf.Configuration{
+		Domains: map[string][]do.Record{
+			"example.com": {{Type: "A", Name: "ddns"}},
+		},
+		CheckPeriod:    100 * time.Millisecond,
+		RequestTimeout: 5 * time.Second,
+	})
+	is.NoErr(err)
+	u.do = dm
+	u.ipprovider = pm
+	n := &fakeNotifier{}
+	u.notifier = n
+
+	go func() {
+		time.Sleep(250 * time.Millisecond)
+		u.Stop()
+	}()
+
+	is.NoErr(u.Start(context.Background()))
+
+	is.Equal(n.types(), []notifier.EventType{
+		notifier.EventStarted,
+		notifier.EventIPChanged,
+		notifier.EventSyncFailed,
+		notifier.EventRecordUpdated,
+		notifier.EventSyncRecovered,
+		notifier.EventStopped,
+	})
+	is.Equal(n.events[1].OldIP, "10.0.5.1")
+	is.Equal(n.events[1].NewIP, "10.0.5.2")
+	is.Equal(n.events[3].Domain, "example.com")
+	is.Equal(n.events[3].OldData, "10.0.5.1")
+	is.Equal(n.events[3].Record.Data, "10.0.5.2")
+	is.Equal(len(dm.UpdateCalls()), 1)
+	is.True(n.closed) // pending notifications are flushed on shutdown
+}
+
+type fakePublisher struct {
+	mu     sync.Mutex
+	ips    []string
+	syncs  []mqtt.SyncStatus
+	closed bool
+}
+
+func (p *fakePublisher) PublishIP(ctx context.Context, ip string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ips = append(p.ips, ip)
+	return nil
+}
+
+func (p *fakePublisher) PublishSync(ctx context.Context, s mqtt.SyncStatus) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.syncs = append(p.syncs, s)
+	return nil
+}
+
+func (p *fakePublisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	return nil
+}
+
+func TestUpdaterPublish(t *testing.T) {
+	is := is.New(t)
+
+	dm := &DomainsServiceMock{
+		ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+			return []do.Record{{ID: 123, Type: "A", Name: "ddns", Data: "10.0.5.1"}}, nil
+		},
+		UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return nil
+		},
+		// www is not published yet and fails to be created
+		CreateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return errors.New("api is down")
+		},
+	}
+
+	pm := &ProviderMock{
+		GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+			return "10.0.5.2", nil
+		},
+	}
+
+	u, err := New(&conf.Configuration{
+		Domains: map[string][]do.Record{
+			"example.com": {{Type: "A", Name: "ddns"}, {Type: "A", Name: "www"}},
+		},
+		CheckPeriod:    time.Hour,
+		RequestTimeout: 5 * time.Second,
+	})
+	is.NoErr(err)
+	u.do = dm
+	u.ipprovider = pm
+	p := &fakePublisher{}
+	u.publisher = p
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		u.Stop()
+	}()
+
+	is.NoErr(u.Start(context.Background()))
+
+	is.Equal(p.ips, []string{"10.0.5.2"})
+	is.Equal(len(p.syncs), 1)
+
+	s := p.syncs[0]
+	is.Equal(s.IP, "10.0.5.2")
+	is.True(s.Error != "")
+	is.Equal(s.Records, []mqtt.RecordStatus{
+		{Domain: "example.com", Type: "A", Name: "ddns", Data: "10.0.5.2", Action: "updated"},
+		{Domain: "example.com", Type: "A", Name: "www", Data: "10.0.5.2", Action: "failed", Error: s.Error},
+	})
+	is.True(p.closed) // ddns is marked as offline on shutdown
+}
+
+type healthProvider struct {
+	health []ipprovider.ProviderHealth
+}
+
+func (p *healthProvider) GetIP(ctx context.Context) (string, error) {
+	return "10.0.5.1", nil
+}
+
+func (p *healthProvider) Health() []ipprovider.ProviderHealth {
+	return p.health
+}
+
+func TestUpdaterLogHealth(t *testing.T) {
+	is := is.New(t)
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	u := &Updater{ipprovider: &ProviderMock{}}
+	u.logHealth()
+	is.Equal(len(hook.AllEntries()), 0) // provider without health is skipped
+
+	u.ipprovider = &healthProvider{health: []ipprovider.ProviderHealth{
+		{Name: "icanhazip", Successes: 3, Failures: 1, Latency: 120 * time.Millisecond, LastError: "timeout"},
+		{Name: "ipify"},
+	}}
+	u.logHealth()
+
+	entries := hook.AllEntries()
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].Message, "ip provider icanhazip: 75% of 4 requests succeeded, average latency 120ms, last error: timeout")
+	is.Equal(entries[1].Message, "ip provider ipify has not been asked yet")
+}
+
+func TestUpdaterIPChangedRecords(t *testing.T) {
+	is := is.New(t)
+
+	var getIPCalls atomic.Int32
+	pm := &ProviderMock{
+		GetIPFunc: func(contextMoqParam context.Context) (string, error) {
+			if getIPCalls.Add(1) == 1 {
+				return "10.0.5.1", nil
+			}
+			return "10.0.5.2", nil
+		},
+	}
+	dm := &DomainsServiceMock{
+		ListFunc: func(contextMoqParam context.Context, s string) ([]do.Record, error) {
+			return []do.Record{{ID: 123, Type: "A", Name: "ddns", Data: "10.0.5.1"}}, nil
+		},
+		UpdateFunc: func(contextMoqParam context.Context, s string, record do.Record) error {
+			return nil
+		},
+	}
+
+	u, err := New(&conf.Configuration{
+		Domains: map[string][]do.Record{
+			"example.com": {{Type: "A", Name: "ddns"}},
+		},
+		CheckPeriod:        50 * time.Millisecond,
+		RequestTimeout:     5 * time.Second,
+		PropagationTimeout: time.Hour,
+		VerifyPropagation:  true,
+	})
+	is.NoErr(err)
+	u.do = dm
+	u.ipprovider = pm
+	// propagation never completes, so the notification must not wait for it
+	u.checker = &fakeChecker{block: true}
+	n := &fakeNotifier{}
+	u.notifier = n
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		u.Stop()
+	}()
+
+	is.NoErr(u.Start(context.Background()))
+
+	var changed []notifier.Event
+	for _, e := range n.events {
+		if e.Type == notifier.EventIPChanged {
+			changed = append(changed, e)
+		}
+	}
+	is.Equal(len(changed), 1)
+	is.Equal(changed[0].Records, []notifier.RecordChange{
+		{Domain: "example.com", Record: do.Record{ID: 123, Type: "A", Name: "ddns", Data: "10.0.5.2"}, OldData: "10.0.5.1"},
+	})
+}